@@ -5,13 +5,49 @@
 // - Fields must be exported. Unexported fields will be ignored.
 // - A field can have the `env` tag which defines the key of the value. If no tag provided, the key will be
 // the uppercase full path of the field (all the fields names starting root until current field, joined by underscore).
-// - The `json` tag will be used for parsing from JSON.
+// - A field marked as required (`required:"true"` or `env:"KEY,required"`) must have a value or a default value.
+// All missing keys are reported at once, each matching ErrRequired. Required fields of a struct pointer which is not
+// set at all are not enforced.
+// - Fields of embedded structs are keyed as if they were declared in the parent struct.
+// - Pointer fields are allocated only if a value (or default) is found for them, or for one of the fields of the
+// pointed struct. Pointers back to a struct containing them (`Next *Node`) are skipped.
+// - Slices and arrays are read from a list separated by the `sep` tag (`sep:";"`), comma by default.
+// Elements are trimmed and empty ones are skipped. A []byte is set to the raw value, unless `sep` is given.
+// - Maps are read from a list of pairs (`a:1,b:2`) with keys and values separated by the `kvsep` tag, colon by
+// default, and from all keys prefixed by the map key (`HEADERS_ACCEPT=value` is added to the `Headers` map),
+// except the keys of the other fields of the struct (`DB_TIMEOUT` of a `DBTimeout` field next to a `DB` map).
+// - Types implementing Decoder, encoding.TextUnmarshaler or encoding.BinaryUnmarshaler (with value or pointer
+// receiver) decode themselves, in this order of precedence.
+// - Types which cannot implement Decoder can be converted by parsers registered with WithParser or RegisterParser.
+// - Values are validated with the tags: `min` and `max` (bounds of numbers and durations, or length of strings,
+// slices, arrays and maps), `len` (exact length), `oneof` (allowed values separated by `|`), `regexp` (pattern of
+// strings), `nonempty` (`nonempty:"true"`). Elements of slices, arrays and maps are checked by `oneof` and `regexp`.
+// Violations match ErrValidation.
+// - Structs implementing Validator (with value or pointer receiver) are validated after being populated, nested
+// structs first, also those in slices, arrays and maps (`Servers[0]`, `Routes[api]`). The method of an embedded
+// struct is called only if the parent struct declares its own, otherwise it is called once as the promoted method
+// of the parent.
+// - JSON objects, YAML mappings, TOML tables and INI sections are bound to nested structs, keyed by the names of
+// the `json`, `yaml`, `toml` or `ini` tag (`json:"port"`, `yaml:"-"` to skip a field) or by the field names, matched
+// case-insensitively. Arrays and sequences are bound to slices and arrays, objects, mappings and tables to maps,
+// with elements of any of these types (`[][]string`, `map[string]Server`).
+// - A time.Duration field accepts Go duration syntax (`2s`, `1h30m`). A number without unit is read using
+// the `unit` tag (`unit:"ms"`), nanoseconds by default. The `unit` tag also marks types defined as time.Duration.
+//
+// All problems found while loading a config are returned at once as Errors of *FieldError, *StructError and
+// *ParseError, holding the field path, the key, the value (redacted for fields tagged `secret:"true"`) and the
+// location in input.
+//
+// The behavior can be changed with options passed to New (WithPrefix, WithTagName, WithStrict etc.).
 //
 // Input sources:
 // - environment variables
 // - environment variables from files
 // - byte array
 // - json
+// - yaml (a subset, see FromYAML)
+// - toml
+// - ini
 package config
 
 import (
@@ -22,21 +58,99 @@ import (
 	"io"
 	"os"
 	"reflect"
+	"slices"
+	"sort"
+	"strings"
 
+	"github.com/andreiavrammsd/config/internal/ini"
 	"github.com/andreiavrammsd/config/internal/interpolator"
 	"github.com/andreiavrammsd/config/internal/parser"
 	"github.com/andreiavrammsd/config/internal/reader"
+	"github.com/andreiavrammsd/config/internal/toml"
+	"github.com/andreiavrammsd/config/internal/yaml"
+)
+
+var (
+	ErrInvalidConfigType = errors.New("config type must be non-nil pointer to struct")
+	ErrUnknownKeys       = errors.New("unknown keys")
+	ErrRequired          = reader.ErrRequired
+	ErrValidation        = reader.ErrValidation
+	ErrUndefined         = interpolator.ErrUndefined
 )
 
-var ErrInvalidConfigType = errors.New("config type must be non-nil pointer to struct")
+type (
+	// FieldError is the problem of binding a value to a field (conversion failure, required key not found).
+	// It holds the path of the field, its key, the raw value (redacted for fields tagged `secret:"true"`)
+	// and the location of the key in the input (file name and line), if known.
+	FieldError = reader.FieldError
+
+	// Errors collects all problems found while loading a config.
+	// Each of them can be retrieved with errors.As (*FieldError, *ParseError).
+	Errors = reader.Errors
+
+	// ParseError is a syntax error in the input, with the position where it was found.
+	ParseError = parser.ParseError
+
+	// StructError is the error returned by the Validate method of a struct, with the path of the struct.
+	StructError = reader.StructError
+)
 
 const dotEnvFile string = ".env"
 
+// Tags naming the fields in hierarchical input.
+const (
+	jsonTag = "json"
+	yamlTag = "yaml"
+	tomlTag = "toml"
+	iniTag  = "ini"
+)
+
+// DuplicatePolicy is the handling of a key defined more than once in dotenv files and bytes (see WithDuplicates).
+type DuplicatePolicy = parser.DuplicatePolicy
+
+const (
+	DuplicateLastWins  = parser.DuplicateLastWins
+	DuplicateFirstWins = parser.DuplicateFirstWins
+	DuplicateError     = parser.DuplicateError
+)
+
+// UndefinedPolicy is the handling of references to variables which are not set in dotenv, TOML and INI input
+// (see WithUndefined).
+type UndefinedPolicy = interpolator.UndefinedPolicy
+
+const (
+	UndefinedEmpty = interpolator.UndefinedEmpty
+	UndefinedError = interpolator.UndefinedError
+	UndefinedKeep  = interpolator.UndefinedKeep
+)
+
+// Decoder is implemented by types which decode themselves from a configuration value.
+// It takes precedence over encoding.TextUnmarshaler and encoding.BinaryUnmarshaler,
+// which are also used if implemented.
+type Decoder interface {
+	Decode(value string) error
+}
+
+// Validator is implemented by structs which validate themselves after being populated,
+// usually to check rules spanning multiple fields.
+type Validator interface {
+	Validate() error
+}
+
+// parseFunc reads variables from input, with their positions and the keys of the literal values,
+// which are not interpolated.
+type parseFunc func(
+	r io.Reader, vars map[string]string, positions map[string]parser.Position, literals map[string]bool,
+) error
+
 // Config exposes the public API.
 type Config struct {
-	parse       func(r io.Reader, vars map[string]string) error
-	interpolate func(map[string]string)
-	read        func(configStruct any, data reader.ValueReader) error
+	parse             parseFunc
+	interpolate       func(vars map[string]string, literals map[string]bool) error
+	interpolateBraced func(vars map[string]string, literals map[string]bool) error
+	read              func(configStruct any, source reader.Source) error
+	readNested        func(configStruct any, source reader.Source, tag string) error
+	strict            bool
 }
 
 // FromFile parses config into struct from one or multiple dotenv files.
@@ -51,6 +165,8 @@ func (c Config) FromFile(config any, files ...string) error {
 	}
 
 	vars := make(map[string]string)
+	positions := make(map[string]parser.Position)
+	literals := make(map[string]bool)
 
 	for i := range files {
 		file, err := os.Open(files[i])
@@ -58,21 +174,19 @@ func (c Config) FromFile(config any, files ...string) error {
 			return fmt.Errorf("%w", err)
 		}
 
-		if err = c.parse(file, vars); err != nil {
+		if err = c.parse(file, vars, positions, literals); err != nil {
 			file.Close()
-			return fmt.Errorf("%w", err)
+			return parseErrors(err)
 		}
 
 		file.Close()
 	}
 
-	c.interpolate(vars)
-
-	if err := c.read(config, func(s *string) string { return vars[*s] }); err != nil {
-		return err
+	if err := c.interpolate(vars, literals); err != nil {
+		return interpolationErrors(err, positions)
 	}
 
-	return nil
+	return c.readVars(config, "", vars, positions)
 }
 
 // FromEnv parses config into struct from environment variables.
@@ -81,7 +195,7 @@ func (c Config) FromEnv(config any) error {
 		return err
 	}
 
-	return c.read(config, func(s *string) string { return os.Getenv(*s) })
+	return c.read(config, reader.Env{})
 }
 
 // FromBytes parses config into struct from byte array.
@@ -91,36 +205,358 @@ func (c Config) FromBytes(config any, input []byte) error {
 	}
 
 	vars := make(map[string]string)
+	positions := make(map[string]parser.Position)
+	literals := make(map[string]bool)
 
-	if err := c.parse(bytes.NewReader(input), vars); err != nil {
-		return err
+	if err := c.parse(bytes.NewReader(input), vars, positions, literals); err != nil {
+		return parseErrors(err)
 	}
 
-	c.interpolate(vars)
+	if err := c.interpolate(vars, literals); err != nil {
+		return interpolationErrors(err, positions)
+	}
 
-	return c.read(config, func(s *string) string { return vars[*s] })
+	return c.readVars(config, "", vars, positions)
 }
 
-// FromJSON parses config into struct from json.
+// FromJSON parses config into struct from json, the same way as dotenv input: objects are bound to nested structs,
+// keyed by the names of the `json` tag or by the field names (matched case-insensitively), arrays to slices and
+// arrays, objects to maps. Defaults, required fields, conversions (durations, decoders), validation and errors
+// are the same as for the other sources. Types implementing json.Unmarshaler (json.RawMessage too) and interface
+// fields (`any`) are decoded from the JSON text of their value, and byte slices from base64 strings,
+// as by encoding/json.
 func (c Config) FromJSON(config any, input json.RawMessage) error {
 	if err := validateConfigType(config); err != nil {
 		return err
 	}
 
-	if err := json.Unmarshal(input, config); err != nil {
+	vars := make(map[string]string)
+	positions := make(map[string]parser.Position)
+	documents := make(map[string]jsonDocument)
+
+	if err := parseJSON(input, vars, positions, documents); err != nil {
+		return err
+	}
+
+	source := jsonSource{varsSource: varsSource{Map: reader.Map(vars), positions: positions}, documents: documents}
+
+	return c.readSource(config, jsonTag, source)
+}
+
+// FromYAML parses config into struct from YAML, the same way as FromJSON: mappings are bound to nested structs,
+// keyed by the names of the `yaml` tag or by the field names (matched case-insensitively), sequences to slices and
+// arrays, mappings to maps. A subset of YAML is supported: anchors, aliases, tags and multiple documents are not.
+// Syntax errors are returned as *ParseError.
+func (c Config) FromYAML(config any, input []byte) error {
+	if err := validateConfigType(config); err != nil {
+		return err
+	}
+
+	return c.readYAML(config, bytes.NewReader(input))
+}
+
+// FromYAMLFile parses config into struct from a YAML file, the same way as FromYAML.
+func (c Config) FromYAMLFile(config any, file string) error {
+	if err := validateConfigType(config); err != nil {
+		return err
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
 		return fmt.Errorf("%w", err)
 	}
+	defer f.Close()
 
-	return nil
+	return c.readYAML(config, f)
+}
+
+func (c Config) readYAML(config any, r io.Reader) error {
+	vars := make(map[string]string)
+	positions := make(map[string]parser.Position)
+
+	if err := yaml.Parse(r, vars, positions); err != nil {
+		return parseErrors(err)
+	}
+
+	return c.readVars(config, yamlTag, vars, positions)
+}
+
+// FromTOML parses config into struct from TOML, the same way as FromJSON: tables are bound to nested structs,
+// keyed by the names of the `toml` tag or by the field names (matched case-insensitively), arrays and arrays of
+// tables to slices and arrays, tables to maps. Datetimes are bound to time.Time fields (local ones in UTC).
+// Braced variables in strings (`${VAR}`, `${server.host}`) are interpolated as in dotenv input, bare ones (`$VAR`)
+// are kept literally, as literal strings (single-quoted). Syntax errors are returned as *ParseError.
+func (c Config) FromTOML(config any, input []byte) error {
+	if err := validateConfigType(config); err != nil {
+		return err
+	}
+
+	return c.readInterpolated(config, bytes.NewReader(input), toml.Parse, tomlTag)
+}
+
+// FromTOMLFile parses config into struct from a TOML file, the same way as FromTOML.
+func (c Config) FromTOMLFile(config any, file string) error {
+	if err := validateConfigType(config); err != nil {
+		return err
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	defer f.Close()
+
+	return c.readInterpolated(config, f, toml.Parse, tomlTag)
+}
+
+// FromINI parses config into struct from INI: sections are bound to nested structs (`[database]` to the `Database`
+// field), keyed by the names of the `ini` tag or by the field names (matched case-insensitively), the same way
+// as FromJSON. Braced variables (`${VAR}`, `${database.host}`) are interpolated as in dotenv input, bare ones
+// (`$VAR`) are kept literally, as single-quoted values. Syntax errors are returned as *ParseError.
+func (c Config) FromINI(config any, input []byte) error {
+	if err := validateConfigType(config); err != nil {
+		return err
+	}
+
+	return c.readInterpolated(config, bytes.NewReader(input), ini.Parse, iniTag)
+}
+
+// FromINIFile parses config into struct from an INI file, the same way as FromINI.
+func (c Config) FromINIFile(config any, file string) error {
+	if err := validateConfigType(config); err != nil {
+		return err
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+	defer f.Close()
+
+	return c.readInterpolated(config, f, ini.Parse, iniTag)
+}
+
+// readInterpolated parses hierarchical input, interpolates its braced variables (except the literal values)
+// and binds them with the keys nested by the tag.
+func (c Config) readInterpolated(config any, r io.Reader, parse parseFunc, tag string) error {
+	vars := make(map[string]string)
+	positions := make(map[string]parser.Position)
+	literals := make(map[string]bool)
+
+	if err := parse(r, vars, positions, literals); err != nil {
+		return parseErrors(err)
+	}
+
+	if err := c.interpolateBraced(vars, literals); err != nil {
+		return interpolationErrors(err, positions)
+	}
+
+	return c.readVars(config, tag, vars, positions)
+}
+
+// parseErrors returns the syntax errors joined by the parser as Errors.
+func parseErrors(err error) error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return Errors(joined.Unwrap())
+	}
+
+	return fmt.Errorf("%w", err)
+}
+
+// interpolationErrors returns the interpolation errors as Errors of *ParseError,
+// located at the keys whose values failed.
+func interpolationErrors(err error, positions map[string]parser.Position) error {
+	all := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		all = joined.Unwrap()
+	}
+
+	errs := make(Errors, 0, len(all))
+
+	for _, err := range all {
+		var interpolationErr *interpolator.Error
+		if !errors.As(err, &interpolationErr) {
+			errs = append(errs, err)
+			continue
+		}
+
+		pos := positions[interpolationErr.Key]
+		errs = append(errs, &ParseError{
+			File:   pos.File,
+			Line:   pos.Line,
+			Column: pos.Column,
+			Msg:    interpolationErr.Error(),
+			Err:    interpolationErr,
+		})
+	}
+
+	return errs
+}
+
+// position converts an offset in the input to a line and column (both starting at 1).
+func position(input []byte, offset int64) (line, column int) {
+	offset = min(max(offset, 1), int64(len(input)))
+	before := input[:offset-1]
+
+	line = bytes.Count(before, []byte("\n")) + 1
+	column = len(before) - bytes.LastIndexByte(before, '\n')
+
+	return line, column
 }
 
 // New creates the config package instance.
-func New() Config {
+func New(opts ...Option) Config {
+	o := options{
+		interpolation: true,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	interpolate := interpolator.New(o.interpolator...).InterpolateExcept
+	interpolateBraced := interpolator.New(append(slices.Clone(o.interpolator), interpolator.WithBracedOnly())...).
+		InterpolateExcept
+
+	if !o.interpolation {
+		interpolate = func(map[string]string, map[string]bool) error { return nil }
+		interpolateBraced = interpolate
+		o.parser = append(o.parser, parser.WithoutInterpolation())
+	}
+
+	readNested := func(configStruct any, source reader.Source, tag string) error {
+		opts := append(slices.Clone(o.reader), reader.WithNestedKeys(tag))
+		return reader.New(opts...).Read(configStruct, source)
+	}
+
 	return Config{
-		parse:       parser.New().Parse,
-		interpolate: interpolator.New().Interpolate,
-		read:        reader.ReadToStruct,
+		parse:             parser.New(o.parser...).Parse,
+		interpolate:       interpolate,
+		interpolateBraced: interpolateBraced,
+		read:              reader.New(o.reader...).Read,
+		readNested:        readNested,
+		strict:            o.strict,
+	}
+}
+
+// readVars binds parsed variables to the config struct, with keys nested by the tag of hierarchical input
+// (`redis.port`), or with flat keys if the tag is empty (`REDIS_PORT`).
+// In strict mode, all variables must be bound.
+func (c Config) readVars(config any, tag string, vars map[string]string, positions map[string]parser.Position) error {
+	return c.readSource(config, tag, varsSource{Map: reader.Map(vars), positions: positions})
+}
+
+// readSource binds the variables of a source to the config struct, the same way as readVars.
+func (c Config) readSource(config any, tag string, vs reader.Source) error {
+	read := c.read
+	if tag != "" {
+		read = func(configStruct any, source reader.Source) error {
+			return c.readNested(configStruct, source, tag)
+		}
+	}
+
+	if !c.strict {
+		return read(config, vs)
+	}
+
+	source := &usedKeysSource{Source: vs, used: make(map[string]bool)}
+	source.documents, _ = vs.(reader.DocumentSource)
+
+	err := read(config, source)
+
+	var unknown []string
+
+	for _, key := range vs.Keys() {
+		if !source.isUsed(key) {
+			unknown = append(unknown, key)
+		}
+	}
+
+	if len(unknown) == 0 {
+		return err
+	}
+
+	sort.Strings(unknown)
+	unknownErr := fmt.Errorf("%w: %s", ErrUnknownKeys, strings.Join(unknown, ", "))
+
+	// Report unknown keys along with the problems of the fields.
+	var errs Errors
+	if errors.As(err, &errs) {
+		return append(errs, unknownErr)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return unknownErr
+}
+
+// varsSource is a source of parsed variables, located by their position in the input.
+type varsSource struct {
+	reader.Map
+	positions map[string]parser.Position
+}
+
+func (s varsSource) Locate(key string) (source string, line int) {
+	pos := s.positions[key]
+
+	return pos.File, pos.Line
+}
+
+// usedKeysSource records the keys looked up by the reader, and the keys of the documents it decodes
+// if the source holds documents.
+type usedKeysSource struct {
+	reader.Source
+	used map[string]bool
+
+	documents reader.DocumentSource
+
+	// Lowercase keys of the decoded documents.
+	decoded []string
+}
+
+func (s *usedKeysSource) Lookup(key string) string {
+	s.used[key] = true
+
+	return s.Source.Lookup(key)
+}
+
+func (s *usedKeysSource) Document(key string) (string, string) {
+	if s.documents == nil {
+		return key, ""
+	}
+
+	sourceKey, document := s.documents.Document(key)
+	if document != "" {
+		s.decoded = append(s.decoded, strings.ToLower(sourceKey))
 	}
+
+	return sourceKey, document
+}
+
+func (s *usedKeysSource) Decodes(typ reflect.Type) bool {
+	return s.documents != nil && s.documents.Decodes(typ)
+}
+
+func (s *usedKeysSource) Decode(document string, v reflect.Value) error {
+	return s.documents.Decode(document, v) //nolint:wrapcheck
+}
+
+// isUsed reports whether a key was looked up, or is part of a decoded document (`redis.port` of `redis`).
+func (s *usedKeysSource) isUsed(key string) bool {
+	if s.used[key] {
+		return true
+	}
+
+	key = strings.ToLower(key)
+	for _, document := range s.decoded {
+		if rest, ok := strings.CutPrefix(key, document); ok && (rest == "" || rest[0] == '.' || rest[0] == '[') {
+			return true
+		}
+	}
+
+	return false
 }
 
 func validateConfigType(config any) error {
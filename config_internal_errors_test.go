@@ -12,7 +12,9 @@ import (
 
 func TestFromFileWithParserError(t *testing.T) {
 	config := &Config{
-		parse: func(_ io.Reader, _ map[string]string) error { return errors.New("parser error") },
+		parse: func(_ io.Reader, _ map[string]string, _ map[string]parser.Position, _ map[string]bool) error {
+			return errors.New("parser error")
+		},
 	}
 
 	err := config.FromFile(&struct{}{}, "testdata/.env")
@@ -29,8 +31,8 @@ func TestFromFileWithParserError(t *testing.T) {
 func TestFromFileWithReaderError(t *testing.T) {
 	config := &Config{
 		parse:       parser.New().Parse,
-		interpolate: interpolator.New().Interpolate,
-		read: func(_ any, _ reader.ValueReader) error {
+		interpolate: interpolator.New().InterpolateExcept,
+		read: func(_ any, _ reader.Source) error {
 			return errors.New("reader error")
 		},
 	}
@@ -48,7 +50,9 @@ func TestFromFileWithReaderError(t *testing.T) {
 
 func TestFromBytesWithParserError(t *testing.T) {
 	config := &Config{
-		parse: func(_ io.Reader, _ map[string]string) error { return errors.New("parser error") },
+		parse: func(_ io.Reader, _ map[string]string, _ map[string]parser.Position, _ map[string]bool) error {
+			return errors.New("parser error")
+		},
 	}
 
 	err := config.FromBytes(&struct{}{}, nil)
@@ -65,8 +69,8 @@ func TestFromBytesWithParserError(t *testing.T) {
 func TestFromBytesWithReaderError(t *testing.T) {
 	config := &Config{
 		parse:       parser.New().Parse,
-		interpolate: interpolator.New().Interpolate,
-		read: func(_ any, _ reader.ValueReader) error {
+		interpolate: interpolator.New().InterpolateExcept,
+		read: func(_ any, _ reader.Source) error {
 			return errors.New("reader error")
 		},
 	}
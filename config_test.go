@@ -2,9 +2,15 @@ package config_test
 
 import (
 	"encoding/json"
+	"errors"
+	"math/big"
+	"net/netip"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/andreiavrammsd/config"
 	"github.com/andreiavrammsd/config/testdata"
@@ -135,11 +141,536 @@ func TestFromJSONWithInvalidInput(t *testing.T) {
 		t.Fatal("error expected")
 	}
 
-	if err.Error() != "invalid character 'i' looking for beginning of value" {
+	if err.Error() != "line 1, column 1: invalid character 'i' looking for beginning of value" {
 		t.Fatal("incorrect error:", err)
 	}
 }
 
+func TestFromJSONWithNestedValues(t *testing.T) {
+	type server struct {
+		Host string
+		Port int `json:"port" default:"80"`
+	}
+
+	input := []byte(`{
+  "service_name": "api",
+  "timeout": "1m30s",
+  "level": "info",
+  "servers": [{"host": "one"}, {"host": "two", "port": 8080}],
+  "ports": [80, 443],
+  "labels": {"team": "core", "Env": "prod"},
+  "secret": null,
+  "tag": "latest"
+}`)
+
+	actual := struct {
+		Name    string        `json:"service_name"`
+		Timeout time.Duration `min:"1s"`
+		Level   Level
+		Servers []server
+		Ports   []int
+		Labels  map[string]string
+		Secret  string `default:"none"`
+		Tag     string `json:"-"`
+	}{}
+
+	if err := config.New().FromJSON(&actual, input); err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	if actual.Name != "api" || actual.Timeout != 90*time.Second || actual.Level != 1 {
+		t.Fatalf("incorrect values: %+v", actual)
+	}
+
+	if !reflect.DeepEqual(actual.Servers, []server{{"one", 80}, {"two", 8080}}) {
+		t.Fatalf("incorrect servers: %+v", actual.Servers)
+	}
+
+	if !reflect.DeepEqual(actual.Ports, []int{80, 443}) {
+		t.Fatalf("incorrect ports: %+v", actual.Ports)
+	}
+
+	if !reflect.DeepEqual(actual.Labels, map[string]string{"team": "core", "Env": "prod"}) {
+		t.Fatalf("incorrect labels: %+v", actual.Labels)
+	}
+
+	if actual.Secret != "none" || actual.Tag != "" {
+		t.Fatalf("incorrect values: %+v", actual)
+	}
+}
+
+// point is decoded from a JSON array of coordinates (`[1, 2]`).
+type point struct {
+	X, Y int
+}
+
+func (p *point) UnmarshalJSON(data []byte) error {
+	var coordinates [2]int
+	if err := json.Unmarshal(data, &coordinates); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	p.X, p.Y = coordinates[0], coordinates[1]
+
+	return nil
+}
+
+func TestFromJSONWithDocuments(t *testing.T) {
+	input := []byte(`{
+  "origin": [1, 2],
+  "path": [[3, 4], [5, 6]],
+  "target": [7, 8],
+  "raw": {"a": [1, null]},
+  "any": {"a": 1, "b": ["c"]},
+  "values": {"a": true, "b": {"c": "d"}},
+  "list": [1, "x"]
+}`)
+
+	actual := struct {
+		Origin point
+		Path   []point
+		Target *point
+		Raw    json.RawMessage
+		Any    any
+		Values map[string]any
+		List   []any
+		Empty  any
+	}{}
+
+	if err := config.New(config.WithStrict()).FromJSON(&actual, input); err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	if actual.Origin != (point{1, 2}) || !reflect.DeepEqual(actual.Path, []point{{3, 4}, {5, 6}}) ||
+		actual.Target == nil || *actual.Target != (point{7, 8}) {
+		t.Fatalf("incorrect unmarshalers: %+v, %+v, %+v", actual.Origin, actual.Path, actual.Target)
+	}
+
+	if string(actual.Raw) != `{"a": [1, null]}` {
+		t.Fatalf("incorrect raw message: %s", actual.Raw)
+	}
+
+	if !reflect.DeepEqual(actual.Any, map[string]any{"a": 1.0, "b": []any{"c"}}) ||
+		!reflect.DeepEqual(actual.Values, map[string]any{"a": true, "b": map[string]any{"c": "d"}}) ||
+		!reflect.DeepEqual(actual.List, []any{1.0, "x"}) || actual.Empty != nil {
+		t.Fatalf("incorrect interfaces: %+v, %+v, %+v, %+v", actual.Any, actual.Values, actual.List, actual.Empty)
+	}
+
+	err := config.New(config.WithStrict()).FromJSON(&struct{ Origin point }{}, []byte(`{
+  "origin": {"x": 1},
+  "other": 1
+}`))
+
+	expected := `line 2: field Origin (key origin): json: cannot unmarshal object into Go value of type [2]int
+unknown keys: other`
+	if err == nil || err.Error() != expected {
+		t.Fatal("incorrect error:", err)
+	}
+}
+
+func TestFromJSONWithErrors(t *testing.T) {
+	input := []byte(`{
+  "port": "http",
+  "redis": {
+    "timeout": 10,
+    "hosts": ["a", 1]
+  }
+}`)
+
+	actual := struct {
+		Port  int
+		Name  string `required:"true"`
+		Redis struct {
+			Timeout time.Duration `unit:"s" max:"5s"`
+			Hosts   []int
+		}
+	}{}
+
+	err := config.New().FromJSON(&actual, input)
+
+	var fieldErr *config.FieldError
+	if !errors.As(err, &fieldErr) || !errors.Is(err, config.ErrRequired) || !errors.Is(err, config.ErrValidation) {
+		t.Fatal("incorrect error:", err)
+	}
+
+	expected := `line 2: field Port (key port): strconv.ParseInt: parsing "http": invalid syntax
+field Name (key Name): required key not found
+line 4: field Redis.Timeout (key redis.timeout): validation failed: must be at most 5s
+line 5: field Redis.Hosts (key redis.hosts[0]): strconv.ParseInt: parsing "a": invalid syntax`
+	if err.Error() != expected {
+		t.Fatal("incorrect error message:", err)
+	}
+
+	err = config.New(config.WithStrict()).FromJSON(&struct{ Port int }{}, []byte(`{"port": 1, "host": "h"}`))
+
+	if !errors.Is(err, config.ErrUnknownKeys) || err.Error() != "unknown keys: host" {
+		t.Fatal("incorrect strict error:", err)
+	}
+
+	err = config.New().FromJSON(&struct{}{}, []byte(`
+  [1, 2]`))
+
+	var parseErr *config.ParseError
+	if !errors.As(err, &parseErr) || err.Error() != "line 2, column 3: object expected" {
+		t.Fatal("incorrect parse error:", err)
+	}
+}
+
+func TestFromYAMLFile(t *testing.T) {
+	expected := testdata.GetExpectedOutput()
+
+	actual := testdata.Config{}
+	if err := config.New().FromYAMLFile(&actual, "testdata/config.yaml"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("\nhave: %v\nwant: %v", actual, expected)
+	}
+
+	err := config.New().FromYAMLFile(&actual, "testdata/missing.yaml")
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("incorrect error:", err)
+	}
+}
+
+func TestFromYAML(t *testing.T) {
+	input := []byte(`service_name: api
+servers:
+  - host: one
+  - host: two
+    port: 8080
+labels: {team: core}
+certificate: |
+  -----BEGIN CERTIFICATE-----
+  MIIB
+  -----END CERTIFICATE-----
+`)
+
+	type server struct {
+		Host string
+		Port int `default:"80"`
+	}
+
+	actual := struct {
+		Name        string `yaml:"service_name"`
+		Servers     []server
+		Labels      map[string]string
+		Certificate string
+		Level       Level `default:"info"`
+	}{}
+
+	if err := config.New().FromYAML(&actual, input); err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	if actual.Name != "api" || actual.Level != 1 {
+		t.Fatalf("incorrect values: %+v", actual)
+	}
+
+	if !reflect.DeepEqual(actual.Servers, []server{{"one", 80}, {"two", 8080}}) {
+		t.Fatalf("incorrect servers: %+v", actual.Servers)
+	}
+
+	if !reflect.DeepEqual(actual.Labels, map[string]string{"team": "core"}) {
+		t.Fatalf("incorrect labels: %+v", actual.Labels)
+	}
+
+	if actual.Certificate != "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n" {
+		t.Fatalf("incorrect certificate: %q", actual.Certificate)
+	}
+}
+
+func TestFromYAMLWithErrors(t *testing.T) {
+	actual := struct {
+		Redis struct {
+			Port int `yaml:"port" max:"65535"`
+		}
+		Name string `required:"true"`
+	}{}
+
+	err := config.New().FromYAML(&actual, []byte("redis:\n  port: 70000\n"))
+
+	expected := "line 2: field Redis.Port (key redis.port): validation failed: must be at most 65535\n" +
+		"field Name (key Name): required key not found"
+	if err == nil || err.Error() != expected {
+		t.Fatal("incorrect error:", err)
+	}
+
+	err = config.New().FromYAML(&actual, []byte("redis:\n  port: 1\n   host: localhost\n"))
+
+	var parseErr *config.ParseError
+	if !errors.As(err, &parseErr) || err.Error() != "line 3, column 4: unexpected indentation" {
+		t.Fatal("incorrect parse error:", err)
+	}
+}
+
+func TestFromTOMLFile(t *testing.T) {
+	expected := testdata.GetExpectedOutput()
+
+	actual := testdata.Config{}
+	if err := config.New().FromTOMLFile(&actual, "testdata/config.toml"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("\nhave: %v\nwant: %v", actual, expected)
+	}
+
+	err := config.New().FromTOMLFile(&actual, "testdata/missing.toml")
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("incorrect error:", err)
+	}
+}
+
+func TestFromTOML(t *testing.T) {
+	t.Setenv("CONFIG_TEST_TOML_PASSWORD", "secret")
+
+	input := []byte(`service_name = "api"
+created = 1979-05-27T07:32:00-08:00
+released = 2024-01-02
+timeout = "1m30s"
+url = "http://${servers.primary.host}:${servers.primary.port}/pa$h"
+password = "${CONFIG_TEST_TOML_PASSWORD}"
+escaped = "\\${servers.primary.host}"
+literal = 'C:\path\${servers.primary.host}'
+multiline = '''${CONFIG_TEST_TOML_PASSWORD}'''
+
+[servers.primary]
+host = "one"
+port = 8080
+
+[[replicas]]
+host = "two"
+
+[[replicas]]
+host = "three"
+port = 8081
+`)
+
+	type server struct {
+		Host string
+		Port int `default:"80"`
+	}
+
+	actual := struct {
+		Name      string `toml:"service_name"`
+		Created   time.Time
+		Released  time.Time
+		Timeout   time.Duration
+		URL       string
+		Password  string
+		Escaped   string
+		Literal   string
+		Multiline string
+		Servers   struct{ Primary server }
+		Replicas  []server
+		Level     Level `default:"info"`
+	}{}
+
+	if err := config.New().FromTOML(&actual, input); err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	if actual.Name != "api" || actual.Timeout != 90*time.Second || actual.Level != 1 {
+		t.Fatalf("incorrect values: %+v", actual)
+	}
+
+	if !actual.Created.Equal(time.Date(1979, 5, 27, 15, 32, 0, 0, time.UTC)) ||
+		!actual.Released.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("incorrect datetimes: %v, %v", actual.Created, actual.Released)
+	}
+
+	if actual.URL != "http://one:8080/pa$h" || actual.Password != "secret" ||
+		actual.Escaped != "${servers.primary.host}" {
+		t.Fatalf("incorrect interpolation: %q, %q, %q", actual.URL, actual.Password, actual.Escaped)
+	}
+
+	if actual.Literal != `C:\path\${servers.primary.host}` || actual.Multiline != "${CONFIG_TEST_TOML_PASSWORD}" {
+		t.Fatalf("incorrect literal strings: %q, %q", actual.Literal, actual.Multiline)
+	}
+
+	if actual.Servers.Primary != (server{"one", 8080}) {
+		t.Fatalf("incorrect primary server: %+v", actual.Servers.Primary)
+	}
+
+	if !reflect.DeepEqual(actual.Replicas, []server{{"two", 80}, {"three", 8081}}) {
+		t.Fatalf("incorrect replicas: %+v", actual.Replicas)
+	}
+}
+
+func TestFromTOMLWithErrors(t *testing.T) {
+	actual := struct {
+		Redis struct {
+			Port int `toml:"port" max:"65535"`
+		}
+		Name string `required:"true"`
+	}{}
+
+	err := config.New().FromTOML(&actual, []byte("[redis]\nport = 70000\n"))
+
+	expected := "line 2: field Redis.Port (key redis.port): validation failed: must be at most 65535\n" +
+		"field Name (key Name): required key not found"
+	if err == nil || err.Error() != expected {
+		t.Fatal("incorrect error:", err)
+	}
+
+	err = config.New().FromTOML(&actual, []byte("[redis]\nport = 1\nport = 2\n"))
+
+	var parseErr *config.ParseError
+	if !errors.As(err, &parseErr) || err.Error() != `line 3, column 1: key "redis.port" already defined` {
+		t.Fatal("incorrect parse error:", err)
+	}
+
+	err = config.New(config.WithUndefined(config.UndefinedError)).
+		FromTOML(&actual, []byte("name = \"${CONFIG_TEST_TOML_UNDEFINED}\"\n"))
+
+	if !errors.As(err, &parseErr) || !errors.Is(err, config.ErrUndefined) {
+		t.Fatal("incorrect interpolation error:", err)
+	}
+
+	if err.Error() != "line 1, column 8: interpolate name: undefined variables: CONFIG_TEST_TOML_UNDEFINED" {
+		t.Fatal("incorrect interpolation error message:", err)
+	}
+}
+
+func TestFromINIFile(t *testing.T) {
+	expected := testdata.GetExpectedOutput()
+
+	actual := testdata.Config{}
+	if err := config.New().FromINIFile(&actual, "testdata/config.ini"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("\nhave: %v\nwant: %v", actual, expected)
+	}
+
+	err := config.New().FromINIFile(&actual, "testdata/missing.ini")
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("incorrect error:", err)
+	}
+}
+
+func TestFromINI(t *testing.T) {
+	t.Setenv("CONFIG_TEST_INI_PASSWORD", "secret")
+
+	input := []byte(`; legacy service
+name = api
+
+[database]
+host = localhost
+port = 5432 ; default port
+password = "${CONFIG_TEST_INI_PASSWORD}"
+url = postgres://${database.host}:${database.port}/db?sslmode=disable#$fragment
+literal = '${database.host}'
+
+[cache]
+hosts = a:6379,b:6379
+ttl = 1m
+`)
+
+	actual := struct {
+		Name     string
+		Database struct {
+			Host     string
+			Port     int
+			Password string
+			URL      string
+			Literal  string
+			Timeout  time.Duration `default:"5s"`
+		}
+		Cache struct {
+			Hosts []string
+			TTL   time.Duration
+		} `ini:"cache"`
+	}{}
+
+	if err := config.New().FromINI(&actual, input); err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	if actual.Name != "api" || actual.Database.Host != "localhost" || actual.Database.Port != 5432 ||
+		actual.Database.Password != "secret" || actual.Database.Timeout != 5*time.Second {
+		t.Fatalf("incorrect values: %+v", actual)
+	}
+
+	if actual.Database.URL != "postgres://localhost:5432/db?sslmode=disable#$fragment" ||
+		actual.Database.Literal != "${database.host}" {
+		t.Fatalf("incorrect interpolation: %q, %q", actual.Database.URL, actual.Database.Literal)
+	}
+
+	if !reflect.DeepEqual(actual.Cache.Hosts, []string{"a:6379", "b:6379"}) || actual.Cache.TTL != time.Minute {
+		t.Fatalf("incorrect cache: %+v", actual.Cache)
+	}
+}
+
+func TestFromINIWithLiteralValues(t *testing.T) {
+	input := []byte(`[database]
+host = localhost
+literal = 'C:\dir\${database.host}'
+escaped = "\\${database.host}"
+ref = ${database.literal}
+`)
+
+	type database struct {
+		Literal string
+		Escaped string
+		Ref     string
+	}
+
+	actual := struct{ Database database }{}
+
+	if err := config.New().FromINI(&actual, input); err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	expected := database{`C:\dir\${database.host}`, "${database.host}", `C:\dir\${database.host}`}
+	if actual.Database != expected {
+		t.Fatalf("incorrect values: %+v", actual.Database)
+	}
+
+	actual = struct{ Database database }{}
+
+	if err := config.New(config.WithInterpolation(false)).FromINI(&actual, input); err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	expected = database{`C:\dir\${database.host}`, `\${database.host}`, "${database.literal}"}
+	if actual.Database != expected {
+		t.Fatalf("incorrect values without interpolation: %+v", actual.Database)
+	}
+}
+
+func TestFromINIWithErrors(t *testing.T) {
+	actual := struct {
+		Redis struct {
+			Port int `ini:"port" max:"65535"`
+		}
+		Name string `required:"true"`
+	}{}
+
+	err := config.New().FromINI(&actual, []byte("[redis]\nport = 70000\n"))
+
+	expected := "line 2: field Redis.Port (key redis.port): validation failed: must be at most 65535\n" +
+		"field Name (key Name): required key not found"
+	if err == nil || err.Error() != expected {
+		t.Fatal("incorrect error:", err)
+	}
+
+	err = config.New().FromINI(&actual, []byte("[redis\nport = 1\n"))
+
+	var parseErr *config.ParseError
+	if !errors.As(err, &parseErr) || err.Error() != "line 1, column 1: unterminated section header" {
+		t.Fatal("incorrect parse error:", err)
+	}
+
+	err = config.New(config.WithStrict()).FromINI(&actual, []byte("name = api\n[redis]\nport = 1\nhost = x\n"))
+	if !errors.Is(err, config.ErrUnknownKeys) {
+		t.Fatal("incorrect strict error:", err)
+	}
+}
+
 func TestWithNilConfigType(t *testing.T) {
 	err := config.New().FromFile(nil)
 
@@ -189,3 +720,596 @@ func TestFromJSONWithInvalidConfigType(t *testing.T) {
 		t.Fatal("incorrect error:", err)
 	}
 }
+
+func TestFromBytesWithMaps(t *testing.T) {
+	input := []byte(`
+FLAGS=a:1,b:2
+HEADERS_X_REQUEST_ID=id
+HEADERS_ACCEPT=text/plain
+`)
+
+	actual := struct {
+		Flags   map[string]int
+		Headers map[string]string
+	}{}
+	if err := config.New().FromBytes(&actual, input); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(actual.Flags, map[string]int{"a": 1, "b": 2}) {
+		t.Errorf("incorrect flags: %v", actual.Flags)
+	}
+
+	if !reflect.DeepEqual(actual.Headers, map[string]string{"X_REQUEST_ID": "id", "ACCEPT": "text/plain"}) {
+		t.Errorf("incorrect headers: %v", actual.Headers)
+	}
+}
+
+func TestFromBytesWithParsers(t *testing.T) {
+	input := []byte(`
+PREFIX=10.0.0.0/8
+NUMBER=123456789012345678901234567890
+NUMBERS=1,2
+`)
+
+	actual := struct {
+		Prefix  netip.Prefix
+		Number  *big.Int
+		Numbers []big.Int
+	}{}
+
+	c := config.New(
+		config.WithParser(reflect.TypeOf(netip.Prefix{}), func(value string) (any, error) {
+			return netip.ParsePrefix(value)
+		}),
+		config.RegisterParser(func(value string) (big.Int, error) {
+			n, ok := new(big.Int).SetString(value, 10)
+			if !ok {
+				return big.Int{}, errors.New("invalid number")
+			}
+			return *n, nil
+		}),
+	)
+
+	if err := c.FromBytes(&actual, input); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual.Prefix.String() != "10.0.0.0/8" {
+		t.Errorf("incorrect prefix: %v", actual.Prefix)
+	}
+
+	if actual.Number.String() != "123456789012345678901234567890" {
+		t.Errorf("incorrect number: %v", actual.Number)
+	}
+
+	if len(actual.Numbers) != 2 || actual.Numbers[1].Int64() != 2 {
+		t.Errorf("incorrect numbers: %v", actual.Numbers)
+	}
+}
+
+func TestFromBytesWithParserError(t *testing.T) {
+	actual := struct{ Prefix netip.Prefix }{}
+
+	c := config.New(config.RegisterParser(netip.ParsePrefix))
+
+	err := c.FromBytes(&actual, []byte(`PREFIX=invalid`))
+
+	if err == nil {
+		t.Fatal("error expected")
+	}
+
+	if err.Error() != `line 1: field Prefix (key PREFIX): netip.ParsePrefix("invalid"): no '/'` {
+		t.Fatal("incorrect error message:", err)
+	}
+}
+
+func TestFromBytesWithOptions(t *testing.T) {
+	input := []byte(`
+APP_HOST=localhost
+APP_redis.port=6379
+APP_URL=$APP_HOST
+APP_level=debug
+`)
+
+	actual := struct {
+		Host  string `key:"HOST"`
+		Redis struct {
+			Port int
+		}
+		URL   string
+		Level string `fallback:"info"`
+		Mode  string `fallback:"release"`
+	}{}
+
+	c := config.New(
+		config.WithTagName("key"),
+		config.WithDefaultTag("fallback"),
+		config.WithPrefix("APP_"),
+		config.WithKeyTransform(func(path string) string {
+			return strings.ToLower(strings.ReplaceAll(path, "_", "."))
+		}),
+		config.WithInterpolation(false),
+	)
+
+	if err := c.FromBytes(&actual, input); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual.Host != "localhost" {
+		t.Errorf("incorrect host: %q", actual.Host)
+	}
+
+	if actual.Redis.Port != 6379 {
+		t.Errorf("incorrect port: %d", actual.Redis.Port)
+	}
+
+	if actual.URL != "$APP_HOST" {
+		t.Errorf("incorrect url: %q", actual.URL)
+	}
+
+	if actual.Level != "debug" {
+		t.Errorf("incorrect level: %q", actual.Level)
+	}
+
+	if actual.Mode != "release" {
+		t.Errorf("incorrect mode: %q", actual.Mode)
+	}
+}
+
+func TestFromBytesWithStrict(t *testing.T) {
+	input := []byte(`
+HOST=localhost
+PORT=
+UNKNOWN=1
+OTHER=2
+`)
+
+	actual := struct {
+		Host string
+		Port int
+	}{}
+
+	err := config.New(config.WithStrict()).FromBytes(&actual, input)
+
+	if !errors.Is(err, config.ErrUnknownKeys) {
+		t.Fatal("incorrect error:", err)
+	}
+
+	if err.Error() != "unknown keys: OTHER, UNKNOWN" {
+		t.Fatal("incorrect error message:", err)
+	}
+
+	if err := config.New(config.WithStrict()).FromBytes(&actual, []byte("HOST=localhost")); err != nil {
+		t.Fatal("error not expected:", err)
+	}
+}
+
+func TestFromBytesWithRequired(t *testing.T) {
+	actual := struct {
+		DB struct {
+			URL string `env:"DB_URL,required"`
+		}
+		Port int `required:"true"`
+		Host string
+	}{}
+
+	err := config.New().FromBytes(&actual, []byte(`HOST=localhost`))
+
+	if !errors.Is(err, config.ErrRequired) {
+		t.Fatal("incorrect error:", err)
+	}
+
+	expected := "field DB.URL (key DB_URL): required key not found\n" +
+		"field Port (key PORT): required key not found"
+
+	if err.Error() != expected {
+		t.Fatal("incorrect error message:", err)
+	}
+}
+
+func TestFromFileWithErrors(t *testing.T) {
+	file := filepath.Join(t.TempDir(), ".env")
+	input := `HOST=localhost
+PORT=http
+UNKNOWN=1
+PASSWORD=secret
+`
+
+	if err := os.WriteFile(file, []byte(input), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	actual := struct {
+		Host     string
+		Port     int
+		Password int `secret:"true"`
+		Timeout  int `required:"true"`
+	}{}
+
+	err := config.New(config.WithStrict()).FromFile(&actual, file)
+
+	var errs config.Errors
+	if !errors.As(err, &errs) {
+		t.Fatal("incorrect error:", err)
+	}
+
+	if len(errs) != 4 {
+		t.Fatal("incorrect number of errors:", err)
+	}
+
+	var fieldErr *config.FieldError
+	if !errors.As(err, &fieldErr) {
+		t.Fatal("field error expected:", err)
+	}
+
+	if fieldErr.Path != "Port" || fieldErr.Key != "PORT" || fieldErr.Value != "http" ||
+		fieldErr.Source != file || fieldErr.Line != 2 {
+		t.Fatalf("incorrect field error: %#v", fieldErr)
+	}
+
+	if !errors.Is(err, config.ErrRequired) || !errors.Is(err, config.ErrUnknownKeys) {
+		t.Fatal("incorrect error:", err)
+	}
+
+	expected := file + `:2: field Port (key PORT): strconv.ParseInt: parsing "http": invalid syntax
+` + file + `:4: field Password (key PASSWORD): invalid value [REDACTED]
+field Timeout (key TIMEOUT): required key not found
+unknown keys: UNKNOWN`
+
+	if err.Error() != expected {
+		t.Fatal("incorrect error message:", err)
+	}
+}
+
+func TestFromJSONWithParseError(t *testing.T) {
+	err := config.New().FromJSON(&struct{}{}, []byte("{\n  \"a\": 1,\n}"))
+
+	var parseErr *config.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatal("incorrect error:", err)
+	}
+
+	if parseErr.Line != 3 || parseErr.Column != 1 {
+		t.Fatalf("incorrect position: %#v", parseErr)
+	}
+}
+
+func TestFromBytesWithValidation(t *testing.T) {
+	actual := struct {
+		Port  int    `min:"1" max:"65535"`
+		Level string `oneof:"debug|info|warn" default:"info"`
+	}{}
+
+	err := config.New().FromBytes(&actual, []byte("PORT=0\nLEVEL=trace"))
+
+	if !errors.Is(err, config.ErrValidation) {
+		t.Fatal("incorrect error:", err)
+	}
+
+	expected := "line 1: field Port (key PORT): validation failed: must be at least 1\n" +
+		"line 2: field Level (key LEVEL): validation failed: must be one of debug|info|warn"
+
+	if err.Error() != expected {
+		t.Fatal("incorrect error message:", err)
+	}
+
+	if err := config.New().FromBytes(&actual, []byte("PORT=80")); err != nil {
+		t.Fatal("error not expected:", err)
+	}
+}
+
+type validatedConfig struct {
+	Pool struct {
+		Min int
+		Max int
+	}
+}
+
+func (c validatedConfig) Validate() error {
+	if c.Pool.Min > c.Pool.Max {
+		return errors.New("pool min greater than max")
+	}
+
+	return nil
+}
+
+func TestFromJSONWithValidator(t *testing.T) {
+	actual := validatedConfig{}
+
+	err := config.New().FromJSON(&actual, []byte(`{"Pool": {"Min": 2, "Max": 1}}`))
+
+	var structErr *config.StructError
+	if !errors.As(err, &structErr) {
+		t.Fatal("incorrect error:", err)
+	}
+
+	if err.Error() != "validate: pool min greater than max" {
+		t.Fatal("incorrect error message:", err)
+	}
+
+	if err := config.New().FromBytes(&actual, []byte("POOL_MIN=1\nPOOL_MAX=2")); err != nil {
+		t.Fatal("error not expected:", err)
+	}
+}
+
+func TestFromBytesWithQuotedValues(t *testing.T) {
+	input := []byte(`
+A=1
+DOUBLE="multiline
+value with $A and \"quotes\"" # comment
+SINGLE='literal $A\n'
+`)
+
+	actual := struct {
+		Double string
+		Single string
+	}{}
+
+	if err := config.New().FromBytes(&actual, input); err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	if actual.Double != "multiline\nvalue with 1 and \"quotes\"" {
+		t.Fatalf("incorrect value: %q", actual.Double)
+	}
+
+	if actual.Single != `literal $A\n` {
+		t.Fatalf("incorrect value: %q", actual.Single)
+	}
+}
+
+func TestFromBytesWithComments(t *testing.T) {
+	input := []byte(`URL=http://host/#fragment # comment
+PASSWORD=ab#cd`)
+
+	actual := struct {
+		URL      string
+		Password string
+	}{}
+
+	if err := config.New().FromBytes(&actual, input); err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	if actual.URL != "http://host/#fragment" || actual.Password != "ab#cd" {
+		t.Fatalf("incorrect values: %+v", actual)
+	}
+
+	if err := config.New(config.WithLegacyComments()).FromBytes(&actual, input); err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	if actual.URL != "http://host/" || actual.Password != "ab" {
+		t.Fatalf("incorrect legacy values: %+v", actual)
+	}
+}
+
+func TestFromFileWithStrictSyntax(t *testing.T) {
+	file := filepath.Join(t.TempDir(), ".env")
+	input := "HOST=localhost\nPORT\n1KEY=value\n"
+
+	if err := os.WriteFile(file, []byte(input), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	actual := struct {
+		Host string
+	}{}
+
+	if err := config.New().FromFile(&actual, file); err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	err := config.New(config.WithStrictSyntax()).FromFile(&actual, file)
+
+	var errs config.Errors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatal("incorrect error:", err)
+	}
+
+	var parseErr *config.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatal("incorrect error:", err)
+	}
+
+	expected := file + `:2:1: missing '=' after key "PORT"
+` + file + `:3:1: invalid key "1KEY"`
+
+	if err.Error() != expected {
+		t.Fatal("incorrect error message:", err)
+	}
+}
+
+func TestFromBytesWithExport(t *testing.T) {
+	input := []byte(`set -a
+export HOST=localhost
+export PORT=8080
+set +a`)
+
+	actual := struct {
+		Host string
+		Port int
+	}{}
+
+	if err := config.New(config.WithStrict(), config.WithStrictSyntax()).FromBytes(&actual, input); err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	if actual.Host != "localhost" || actual.Port != 8080 {
+		t.Fatalf("incorrect values: %+v", actual)
+	}
+}
+
+func TestFromFileWithDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, ".env")
+	second := filepath.Join(dir, ".env.local")
+
+	if err := os.WriteFile(first, []byte("HOST=localhost\nPORT=80\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(second, []byte("\nPORT=8080\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	actual := struct {
+		Host string
+		Port int
+	}{}
+
+	if err := config.New().FromFile(&actual, first, second); err != nil || actual.Port != 8080 {
+		t.Fatal("incorrect result:", actual, err)
+	}
+
+	err := config.New(config.WithDuplicates(config.DuplicateFirstWins)).FromFile(&actual, first, second)
+	if err != nil || actual.Port != 80 {
+		t.Fatal("incorrect result:", actual, err)
+	}
+
+	err = config.New(config.WithDuplicates(config.DuplicateError)).FromFile(&actual, first, second)
+
+	var parseErr *config.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatal("incorrect error:", err)
+	}
+
+	if err.Error() != second+`:2:1: duplicate key "PORT" (first defined at `+first+`:2:1)` {
+		t.Fatal("incorrect error message:", err)
+	}
+}
+
+func TestFromBytesWithParameterExpansion(t *testing.T) {
+	input := []byte(`HOST=${DB_HOST:-localhost}
+PORT=${DB_PORT-5432}
+URL=postgres://${DB_USER:?database user is required}@${HOST}:${PORT}`)
+
+	actual := struct {
+		URL string
+	}{}
+
+	err := config.New().FromBytes(&actual, input)
+
+	var parseErr *config.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatal("incorrect error:", err)
+	}
+
+	if err.Error() != "line 3, column 1: interpolate URL: DB_USER: database user is required" {
+		t.Fatal("incorrect error message:", err)
+	}
+
+	input = append([]byte("DB_USER=admin\n"), input...)
+
+	if err := config.New().FromBytes(&actual, input); err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	if actual.URL != "postgres://admin@localhost:5432" {
+		t.Fatalf("incorrect value: %q", actual.URL)
+	}
+}
+
+func TestFromBytesWithInterpolationCycle(t *testing.T) {
+	input := []byte(`URL=http://$HOST
+HOST=$URL`)
+
+	err := config.New().FromBytes(&struct{ URL string }{}, input)
+
+	if err == nil || err.Error() != "line 2, column 1: interpolate HOST: reference cycle: HOST -> URL -> HOST" {
+		t.Fatal("incorrect error:", err)
+	}
+}
+
+func TestFromBytesWithLiteralValues(t *testing.T) {
+	input := []byte(`A=1
+SQ='cost $5 \$A ${A}'
+DQ="a\\$A \$A"
+REF=${SQ}`)
+
+	actual := struct {
+		SQ  string
+		DQ  string
+		REF string
+	}{}
+
+	if err := config.New().FromBytes(&actual, input); err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	if actual.SQ != "cost $5 \\$A ${A}" || actual.DQ != "a\\1 $A" || actual.REF != actual.SQ {
+		t.Fatalf("incorrect values: %+v", actual)
+	}
+
+	if err := config.New(config.WithInterpolation(false)).FromBytes(&actual, input); err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	if actual.SQ != "cost $5 \\$A ${A}" || actual.DQ != "a\\$A \\$A" || actual.REF != "${SQ}" {
+		t.Fatalf("incorrect values without interpolation: %+v", actual)
+	}
+}
+
+func TestFromBytesWithLookups(t *testing.T) {
+	t.Setenv("CONFIG_TEST_DB_USER", "env_user")
+
+	lookup := func(name string) (string, bool) {
+		if name == "CONFIG_TEST_DB_PASSWORD" {
+			return "secret", true
+		}
+
+		return "", false
+	}
+
+	input := []byte(`DB_URL=postgres://${CONFIG_TEST_DB_USER:-nobody}:$CONFIG_TEST_DB_PASSWORD`)
+
+	actual := struct {
+		DB struct {
+			URL string
+		}
+	}{}
+
+	if err := config.New(config.WithLookup(lookup)).FromBytes(&actual, input); err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	if actual.DB.URL != "postgres://env_user:secret" {
+		t.Fatalf("incorrect value: %q", actual.DB.URL)
+	}
+
+	if err := config.New(config.WithoutEnvLookup()).FromBytes(&actual, input); err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	if actual.DB.URL != "postgres://nobody:" {
+		t.Fatalf("incorrect value: %q", actual.DB.URL)
+	}
+}
+
+func TestFromBytesWithUndefinedVariables(t *testing.T) {
+	input := []byte(`HOST=localhost
+URL=http://${HOST}:${CONFIG_TEST_PORT}/${CONFIG_TEST_PATH}`)
+
+	actual := struct {
+		URL string
+	}{}
+
+	if err := config.New(config.WithUndefined(config.UndefinedKeep)).FromBytes(&actual, input); err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	if actual.URL != "http://localhost:${CONFIG_TEST_PORT}/${CONFIG_TEST_PATH}" {
+		t.Fatalf("incorrect value: %q", actual.URL)
+	}
+
+	err := config.New(config.WithUndefined(config.UndefinedError)).FromBytes(&actual, input)
+
+	if !errors.Is(err, config.ErrUndefined) {
+		t.Fatal("incorrect error:", err)
+	}
+
+	if err.Error() != "line 2, column 1: interpolate URL: undefined variables: CONFIG_TEST_PORT, CONFIG_TEST_PATH" {
+		t.Fatal("incorrect error message:", err)
+	}
+}
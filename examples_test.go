@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net"
 	"os"
 
 	"github.com/andreiavrammsd/config"
@@ -82,3 +83,89 @@ func ExampleConfig_FromJSON() {
 	// Output:
 	// msd
 }
+
+func ExampleConfig_FromYAML() {
+	configuration := Configuration{}
+	input := []byte("username: msd\ntimeout: 30\n")
+
+	c := config.New()
+
+	if err := c.FromYAML(&configuration, input); err != nil {
+		log.Fatalf("cannot parse config: %s", err)
+	}
+
+	fmt.Println(configuration.Username, configuration.Tag, configuration.Timeout)
+
+	// Output:
+	// msd none 30
+}
+
+func ExampleConfig_FromTOML() {
+	configuration := Configuration{}
+	input := []byte("username = \"msd\"\ntimeout = 30\n")
+
+	c := config.New()
+
+	if err := c.FromTOML(&configuration, input); err != nil {
+		log.Fatalf("cannot parse config: %s", err)
+	}
+
+	fmt.Println(configuration.Username, configuration.Tag, configuration.Timeout)
+
+	// Output:
+	// msd none 30
+}
+
+func ExampleConfig_FromINI() {
+	configuration := struct {
+		Database struct {
+			Host string
+			Port int
+			URL  string
+		}
+	}{}
+	input := []byte("[database]\nhost = localhost\nport = 5432 ; comment\nurl = ${database.host}:${database.port}\n")
+
+	if err := config.New().FromINI(&configuration, input); err != nil {
+		log.Fatalf("cannot parse config: %s", err)
+	}
+
+	fmt.Println(configuration.Database.URL)
+
+	// Output:
+	// localhost:5432
+}
+
+type Level int
+
+func (l *Level) Decode(value string) error {
+	switch value {
+	case "debug":
+		*l = 0
+	case "info":
+		*l = 1
+	default:
+		return fmt.Errorf("unknown level %q", value)
+	}
+
+	return nil
+}
+
+func ExampleDecoder() {
+	configuration := struct {
+		Level Level
+		IP    net.IP
+	}{}
+	input := []byte("LEVEL=info\nIP=127.0.0.1")
+
+	if err := config.New().FromBytes(&configuration, input); err != nil {
+		log.Fatalf("cannot parse config: %s", err)
+	}
+
+	fmt.Println(configuration.Level)
+	fmt.Println(configuration.IP)
+
+	// Output:
+	// 1
+	// 127.0.0.1
+}
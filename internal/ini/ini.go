@@ -0,0 +1,194 @@
+// Package ini parses INI files into variables keyed by section and key (`database.host`).
+package ini
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/andreiavrammsd/config/internal/parser"
+)
+
+// Byte order mark some editors write at the start of a file.
+const byteOrderMark = "\ufeff"
+
+// Characters escaped by a backslash in double-quoted values.
+var escapes = map[byte]string{'n': "\n", 'r': "\r", 't': "\t", '"': "\"", '\\': "\\"}
+
+type document struct {
+	file      string
+	vars      map[string]string
+	positions map[string]parser.Position
+	literals  map[string]bool
+
+	// Name of the current section, empty before the first one.
+	section string
+}
+
+// Parse reads an INI file and adds to the vars map a variable for each key, prefixed by the name of its section
+// and a dot (`[database]` and `host = localhost` set `database.host`). Keys before the first section are not prefixed.
+// A key defined more than once keeps its last value, and a section can be continued by repeating its header.
+//
+// Lines beginning with `;` or `#` are comments, as the text after `;` or `#` preceded by whitespace in unquoted
+// values (`url = http://host/#fragment ; comment`). Unquoted values are trimmed. Double-quoted values process
+// the escape sequences `\n`, `\r`, `\t`, `\"`, `\\`, single-quoted values are kept literally.
+//
+// If positions is not nil, the position of each variable is added to it.
+// If the reader has a name (like *os.File), it is used as file of the positions.
+// If literals is not nil, the keys of single-quoted values, which must not be interpolated, are added to it.
+//
+// The first problem found is returned as *parser.ParseError.
+func Parse(
+	r io.Reader, vars map[string]string, positions map[string]parser.Position, literals map[string]bool,
+) error {
+	input, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	d := &document{vars: vars, positions: positions, literals: literals}
+
+	if named, ok := r.(interface{ Name() string }); ok {
+		d.file = named.Name()
+	}
+
+	lines := strings.Split(strings.TrimPrefix(string(input), byteOrderMark), "\n")
+	for i, line := range lines {
+		if err := d.line(i+1, strings.TrimSuffix(line, "\r")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// line reads a section header (`[section]`), a key/value pair (`key = value`), a comment or an empty line.
+func (d *document) line(number int, line string) error {
+	content := strings.TrimLeft(line, " \t")
+	column := utf8.RuneCountInString(line[:len(line)-len(content)]) + 1
+
+	switch {
+	case content == "" || content[0] == ';' || content[0] == '#':
+		return nil
+	case content[0] == '[':
+		return d.header(number, column, content)
+	}
+
+	key, value, found := strings.Cut(content, "=")
+	key = strings.TrimSpace(key)
+
+	if !found || key == "" {
+		return d.errorf(number, column, `expected "key = value"`)
+	}
+
+	value = strings.TrimLeft(value, " \t")
+	valueColumn := utf8.RuneCountInString(line[:len(line)-len(value)]) + 1
+
+	literal := value != "" && value[0] == '\''
+
+	value, err := d.value(number, valueColumn, value)
+	if err != nil {
+		return err
+	}
+
+	if d.section != "" {
+		key = d.section + "." + key
+	}
+
+	d.vars[key] = value
+
+	if d.positions != nil {
+		d.positions[key] = parser.Position{File: d.file, Line: number, Column: column}
+	}
+
+	if d.literals != nil {
+		if literal {
+			d.literals[key] = true
+		} else {
+			delete(d.literals, key)
+		}
+	}
+
+	return nil
+}
+
+// header reads the name of a section (`[database]`), which can be followed only by a comment.
+func (d *document) header(number, column int, content string) error {
+	end := strings.IndexByte(content, ']')
+	if end == -1 {
+		return d.errorf(number, column, "unterminated section header")
+	}
+
+	if rest := strings.TrimSpace(content[end+1:]); rest != "" && rest[0] != ';' && rest[0] != '#' {
+		return d.errorf(number, column+utf8.RuneCountInString(content[:end+1]), "unexpected text after section header")
+	}
+
+	d.section = strings.TrimSpace(content[1:end])
+	if d.section == "" {
+		return d.errorf(number, column, "empty section name")
+	}
+
+	return nil
+}
+
+// value reads a quoted or an unquoted value, starting at the given column.
+func (d *document) value(number, column int, value string) (string, error) {
+	if value == "" || value[0] != '"' && value[0] != '\'' {
+		return unquoted(value), nil
+	}
+
+	quote := value[0]
+
+	var b strings.Builder
+
+	for i := 1; i < len(value); i++ {
+		c := value[i]
+
+		switch {
+		case c == quote:
+			if rest := strings.TrimSpace(value[i+1:]); rest != "" && rest[0] != ';' && rest[0] != '#' {
+				return "", d.errorf(number, column+utf8.RuneCountInString(value[:i+1]), "unexpected text after quoted value")
+			}
+
+			return b.String(), nil
+		case quote == '"' && c == '\\' && i+1 < len(value):
+			i++
+			b.WriteString(escape(value[i:]))
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return "", d.errorf(number, column, "unterminated quoted value")
+}
+
+// escape returns the text of the escape sequence after a backslash. Unknown escape sequences are kept
+// with their backslash (`C:\dir`), as the one escaping dollar signs for interpolation (`\${VAR}`).
+func escape(sequence string) string {
+	if s, ok := escapes[sequence[0]]; ok {
+		return s
+	}
+
+	return "\\" + sequence[:1]
+}
+
+// unquoted returns the value without the comment following it and without trailing whitespace.
+func unquoted(value string) string {
+	for i := 1; i < len(value); i++ {
+		if (value[i] == ';' || value[i] == '#') && (value[i-1] == ' ' || value[i-1] == '\t') {
+			value = value[:i]
+			break
+		}
+	}
+
+	if value != "" && (value[0] == ';' || value[0] == '#') {
+		return ""
+	}
+
+	return strings.TrimRight(value, " \t")
+}
+
+func (d *document) errorf(line, column int, format string, args ...any) error {
+	return &parser.ParseError{File: d.file, Line: line, Column: column, Msg: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,198 @@
+package ini_test
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/andreiavrammsd/config/internal/ini"
+	"github.com/andreiavrammsd/config/internal/parser"
+)
+
+func parse(t *testing.T, input string) map[string]string {
+	t.Helper()
+
+	vars := make(map[string]string)
+	if err := ini.Parse(strings.NewReader(input), vars, nil, nil); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	return vars
+}
+
+func assertVars(t *testing.T, actual, expected map[string]string) {
+	t.Helper()
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("\nhave: %q\nwant: %q", actual, expected)
+	}
+}
+
+func TestParseSections(t *testing.T) {
+	vars := parse(t, "\ufeff; comment\r\n"+`name = api
+# comment
+[database]
+host=localhost
+  port = 5432 ; comment
+url = postgres://host/db#fragment # comment
+empty =
+
+[ database.replica ] ; comment
+host = replica
+
+[cache]
+ttl = 1m
+[database]
+user = admin
+user = root
+`)
+
+	assertVars(t, vars, map[string]string{
+		"name":                  "api",
+		"database.host":         "localhost",
+		"database.port":         "5432",
+		"database.url":          "postgres://host/db#fragment",
+		"database.empty":        "",
+		"database.replica.host": "replica",
+		"cache.ttl":             "1m",
+		"database.user":         "root",
+	})
+}
+
+func TestParseQuotedValues(t *testing.T) {
+	vars := parse(t, `double = "  spaced ; not a comment  " ; comment
+escaped = "tab\t \"quoted\" C:\dir \\"
+variables = "\${A} \\${B} ${C}"
+single = 'no \t escape ${VAR} $VAR'
+unquoted = it's "text"
+`)
+
+	assertVars(t, vars, map[string]string{
+		"double":    "  spaced ; not a comment  ",
+		"escaped":   "tab\t \"quoted\" C:\\dir \\",
+		"variables": `\${A} \${B} ${C}`,
+		"single":    `no \t escape ${VAR} $VAR`,
+		"unquoted":  `it's "text"`,
+	})
+}
+
+func TestParseLiterals(t *testing.T) {
+	input := `a = '${A}'
+b = "${B}"
+c = ${C}
+[d]
+a = '${A}'
+b = 'x'
+b = y
+`
+	vars := make(map[string]string)
+	literals := make(map[string]bool)
+
+	if err := ini.Parse(strings.NewReader(input), vars, nil, literals); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	expected := map[string]bool{"a": true, "d.a": true}
+	if !reflect.DeepEqual(literals, expected) {
+		t.Fatalf("\nhave: %v\nwant: %v", literals, expected)
+	}
+}
+
+func TestParseWithPositions(t *testing.T) {
+	file, err := os.CreateTemp(t.TempDir(), "*.ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := file.WriteString("a = 1\n[b]\n  c = 2\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := file.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	defer file.Close()
+
+	vars := make(map[string]string)
+	positions := make(map[string]parser.Position)
+
+	if err := ini.Parse(file, vars, positions, nil); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	expected := map[string]parser.Position{
+		"a":   {File: file.Name(), Line: 1, Column: 1},
+		"b.c": {File: file.Name(), Line: 3, Column: 3},
+	}
+
+	if !reflect.DeepEqual(positions, expected) {
+		t.Fatalf("\nhave: %v\nwant: %v", positions, expected)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		expected string
+	}{
+		"missing equals": {"a = 1\n  b", `line 2, column 3: expected "key = value"`},
+		"missing key":    {" = 1", `line 1, column 2: expected "key = value"`},
+		"header":         {"[a", "line 1, column 1: unterminated section header"},
+		"after header":   {"[a] b", "line 1, column 4: unexpected text after section header"},
+		"empty section":  {"[ ]", "line 1, column 1: empty section name"},
+		"unterminated":   {`a = "value`, "line 1, column 5: unterminated quoted value"},
+		"after quoted":   {`a = 'value' text`, "line 1, column 12: unexpected text after quoted value"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := ini.Parse(strings.NewReader(test.input), make(map[string]string), nil, nil)
+
+			var parseErr *parser.ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatal("incorrect error:", err)
+			}
+
+			if err.Error() != test.expected {
+				t.Fatalf("incorrect error message: %q", err)
+			}
+		})
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	assertVars(t, parse(t, ""), map[string]string{})
+	assertVars(t, parse(t, "; comment\n\n[section]\n"), map[string]string{})
+}
+
+func FuzzParse(f *testing.F) {
+	testcases := []string{
+		"a = 1\n[b]\nc = \"d\" ; e\n",
+		"[a]\nb = 'c ${d}'\n",
+		"a",
+		`a = "ü"`,
+		"",
+	}
+	for _, tc := range testcases {
+		f.Add(tc)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		varsFirst := make(map[string]string)
+		errFirst := ini.Parse(strings.NewReader(input), varsFirst, nil, nil)
+
+		varsSecond := make(map[string]string)
+		errSecond := ini.Parse(strings.NewReader(input), varsSecond, nil, nil)
+
+		if (errFirst == nil) != (errSecond == nil) {
+			t.Errorf("Before: %v, after: %v", errFirst, errSecond)
+		}
+
+		if !reflect.DeepEqual(varsFirst, varsSecond) {
+			t.Error("Different results")
+		}
+	})
+}
@@ -1,17 +1,44 @@
 package interpolator
 
 import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sort"
 	"strings"
-	"unicode"
 )
 
-type value struct {
-	content string
-	i       int
+var (
+	// ErrCycle is the error of variables referencing each other.
+	ErrCycle = errors.New("reference cycle")
+
+	// ErrUndefined is the error of references to variables which are not set, with UndefinedError policy.
+	ErrUndefined = errors.New("undefined variables")
+)
+
+// Operators of parameter expansion (`${VAR:-default}`), longest first.
+var operators = []string{":-", ":?", ":+", "-", "?", "+"}
+
+// Error is the failure of interpolating the value of a key.
+type Error struct {
+	Key string
+	Err error
 }
 
-func (ip *value) atEnd() bool {
-	return ip.peek(1) == 0
+func (e *Error) Error() string {
+	return fmt.Sprintf("interpolate %s: %s", e.Key, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// value is the text being interpolated, scanned rune by rune.
+type value struct {
+	content []rune
+	i       int
 }
 
 // nextVarIsDoubleEscaped detects: `\\$“.
@@ -25,7 +52,7 @@ func (ip *value) nextVarIsEscaped() bool {
 }
 
 func (ip *value) current() rune {
-	return rune(ip.content[ip.i])
+	return ip.content[ip.i]
 }
 
 func (ip *value) peek(steps int) rune {
@@ -33,30 +60,140 @@ func (ip *value) peek(steps int) rune {
 		return 0
 	}
 
-	return rune(ip.content[ip.i+steps])
+	return ip.content[ip.i+steps]
 }
 
-func (ip *value) isOpenBrace() bool {
-	return ip.current() == '{'
-}
+// closingBrace returns the index of the brace closing the one after the current dollar sign (`${...}`),
+// considering nested braces, or -1 if there is none.
+func (ip *value) closingBrace() int {
+	if ip.peek(1) != '{' {
+		return -1
+	}
+
+	depth := 0
+
+	for i := ip.i + 1; i < len(ip.content); i++ {
+		switch ip.content[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
 
-func (ip *value) isCloseBrace() bool {
-	return ip.current() == '}'
+	return -1
 }
 
-func (ip *value) isAtSpace() bool {
-	return unicode.IsSpace(ip.current())
+// nameEnd returns the index after the name following the current dollar sign (`$NAME`),
+// which ends at the first character which is not a letter, a digit or an underscore.
+func (ip *value) nameEnd() int {
+	end := ip.i + 1
+	for end < len(ip.content) && isNameCharacter(ip.content[end]) {
+		end++
+	}
+
+	return end
 }
 
 type variable struct {
-	name  []rune
 	value []rune
 }
 
 type Interpolator struct {
-	// All variables to be interpolated.
+	// All variables to be interpolated, with their values before interpolation.
 	vars map[string]string
 
+	// Values of the variables already interpolated.
+	resolved map[string]string
+
+	// Variables kept literally, not interpolated.
+	literals map[string]bool
+
+	// Variables which cannot be interpolated.
+	failed map[string]bool
+
+	// Variables being interpolated, each one referenced by the previous one: `A -> B -> C`.
+	resolving []string
+
+	// Errors of the variables which cannot be interpolated.
+	errs map[string]error
+
+	// Variables not found in the map are looked up in the environment.
+	env bool
+
+	// Functions to look up variables not found in the map or in the environment, in order.
+	lookups []LookupFunc
+
+	// Handling of references to variables which are not set.
+	undefinedPolicy UndefinedPolicy
+
+	// Variables which are not set, by the keys referencing them.
+	undefined map[string][]string
+
+	// Only braced variables are interpolated (`${VAR}`), bare ones are kept literally (`$VAR`).
+	bracedOnly bool
+}
+
+// UndefinedPolicy is the handling of references to variables which are not set (`$UNDEFINED`).
+type UndefinedPolicy byte
+
+const (
+	// UndefinedEmpty replaces the reference with an empty string.
+	UndefinedEmpty UndefinedPolicy = iota
+
+	// UndefinedError reports all the variables which are not set, for each key referencing them.
+	UndefinedError
+
+	// UndefinedKeep keeps the reference literally (`$UNDEFINED`, `${UNDEFINED}`).
+	UndefinedKeep
+)
+
+// LookupFunc returns the value of a variable and whether it is set.
+type LookupFunc func(name string) (string, bool)
+
+// Option configures the Interpolator.
+type Option func(*Interpolator)
+
+// WithoutEnv disables the lookup of variables in the environment.
+func WithoutEnv() Option {
+	return func(ip *Interpolator) {
+		ip.env = false
+	}
+}
+
+// WithUndefined sets the handling of references to variables which are not set (UndefinedEmpty by default).
+// Expansions handling unset variables (`${VAR:-default}`, `${VAR:+alt}` etc.) are not affected.
+func WithUndefined(policy UndefinedPolicy) Option {
+	return func(ip *Interpolator) {
+		ip.undefinedPolicy = policy
+	}
+}
+
+// WithBracedOnly interpolates only braced variables (`${VAR}`), keeping bare ones literally (`$VAR`),
+// for values where a dollar sign is usually text. Only `\${` is an escape sequence.
+func WithBracedOnly() Option {
+	return func(ip *Interpolator) {
+		ip.bracedOnly = true
+	}
+}
+
+// WithLookup adds a function to look up variables not found in the map or in the environment.
+func WithLookup(lookup LookupFunc) Option {
+	return func(ip *Interpolator) {
+		ip.lookups = append(ip.lookups, lookup)
+	}
+}
+
+// scanner interpolates a single value.
+type scanner struct {
+	ip *Interpolator
+
+	// Key of the value.
+	key string
+
 	// Value before interpolation of current analyzed variable: `ABC $VAR TEXT`.
 	rawValue value
 
@@ -79,114 +216,379 @@ type Interpolator struct {
 //	A=1
 //	B=text 1
 //	C=$B
-func (ip *Interpolator) Interpolate(vars map[string]string) {
-	ip.vars = vars
+//
+// Braced variables support shell parameter expansion:
+//
+//	${VAR:-default}  default if VAR is not set or empty
+//	${VAR-default}   default if VAR is not set
+//	${VAR:?message}  error with message if VAR is not set or empty
+//	${VAR?message}   error with message if VAR is not set
+//	${VAR:+alt}      alt if VAR is set and not empty, empty otherwise
+//	${VAR+alt}       alt if VAR is set, empty otherwise
+//
+// The default, message and alt words are interpolated too.
+//
+// Variables not found in the map are looked up in the environment (see WithoutEnv), then with the lookup
+// functions (see WithLookup).
+//
+// Variables are interpolated in order of their dependencies, each one once, regardless of the order of the map:
+// a variable is interpolated before the variables referencing it. A variable referencing itself,
+// directly or through other variables, is a reference cycle (`A -> B -> A`).
+//
+// Errors are returned as *Error for each key, joined. The values of the keys with errors,
+// and of the keys referencing them, are not changed.
+func (ip *Interpolator) Interpolate(vars map[string]string) error {
+	return ip.InterpolateExcept(vars, nil)
+}
+
+// InterpolateExcept interpolates the variables like Interpolate, except the literal ones (single-quoted values),
+// which are kept as they are, also when referenced by other variables.
+func (ip *Interpolator) InterpolateExcept(vars map[string]string, literals map[string]bool) error {
+	ip.vars = maps.Clone(vars)
+	ip.literals = literals
+	ip.resolved = make(map[string]string, len(vars))
+	ip.failed = make(map[string]bool)
+	ip.resolving = nil
+	ip.errs = make(map[string]error)
+	ip.undefined = make(map[string][]string)
+
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
 
-	for key, value := range ip.vars {
-		ip.rawValue.content = value
+	for _, key := range keys {
+		if value, ok := ip.resolve(key); ok {
+			vars[key] = value
+		}
+	}
+
+	errs := make([]error, 0, len(ip.errs))
 
-		if ip.rawValueContainsVars() {
-			ip.parseVars()
-			vars[key] = string(ip.interpolatedVar.value)
+	for _, key := range keys {
+		if err, ok := ip.errs[key]; ok {
+			errs = append(errs, &Error{Key: key, Err: err})
 		}
 	}
+
+	return errors.Join(errs...)
 }
 
-func (ip *Interpolator) rawValueContainsVars() bool {
-	return strings.IndexByte(ip.rawValue.content, '$') != -1
+// resolve returns the interpolated value of a variable, interpolating it (and its dependencies) if needed.
+// It reports false if the variable cannot be interpolated.
+func (ip *Interpolator) resolve(key string) (string, bool) {
+	if value, ok := ip.resolved[key]; ok {
+		return value, true
+	}
+
+	if ip.failed[key] {
+		return "", false
+	}
+
+	if i := slices.Index(ip.resolving, key); i != -1 {
+		cycle := append(slices.Clone(ip.resolving[i:]), key)
+		ip.fail(key, fmt.Errorf("%w: %s", ErrCycle, strings.Join(cycle, " -> ")))
+
+		return "", false
+	}
+
+	if ip.literals[key] || !containsVars(ip.vars[key]) {
+		ip.resolved[key] = ip.vars[key]
+		return ip.vars[key], true
+	}
+
+	s := &scanner{ip: ip, key: key, rawValue: value{content: []rune(ip.vars[key])}}
+
+	ip.resolving = append(ip.resolving, key)
+	ok := s.parseVars()
+	ip.resolving = ip.resolving[:len(ip.resolving)-1]
+
+	if names := ip.undefined[key]; ok && len(names) > 0 {
+		ip.fail(key, fmt.Errorf("%w: %s", ErrUndefined, strings.Join(names, ", ")))
+		ok = false
+	}
+
+	if !ok {
+		ip.failed[key] = true
+		return "", false
+	}
+
+	ip.resolved[key] = string(s.interpolatedVar.value)
+
+	return ip.resolved[key], true
 }
 
-func (ip *Interpolator) parseVars() {
-	atVar := false // Notifies we're in the context of a variable: `text $IT_IS_HERE more text`.
-	ip.interpolatedVar.name = nil
-	ip.interpolatedVar.value = nil
+// lookup returns the interpolated value of a variable referenced by the value of a key, and whether it is set.
+// It reports false if the variable cannot be interpolated.
+//
+// Variables are looked up in the map, then in the environment, then with the lookup functions.
+// A variable referencing itself (`PATH=$PATH:/bin`) is looked up only in the other sources.
+func (ip *Interpolator) lookup(name, key string) (value string, set, ok bool) {
+	if _, inMap := ip.vars[name]; inMap && name != key {
+		value, ok = ip.resolve(name)
+		return value, true, ok
+	}
 
-	for ip.rawValue.i = 0; ip.rawValue.i < len(ip.rawValue.content); ip.rawValue.i++ {
-		// Variable starts now. Continue to get its name and value.
-		if ip.varStarts() {
-			atVar = true
-			continue
+	if value, set = ip.lookupExternal(name); set || name != key {
+		return value, set, true
+	}
+
+	// Not found elsewhere, so it is a reference cycle.
+	value, ok = ip.resolve(name)
+
+	return value, true, ok
+}
+
+// lookupExternal looks up a variable in the environment and with the lookup functions.
+// Their values are not interpolated.
+func (ip *Interpolator) lookupExternal(name string) (string, bool) {
+	if ip.env {
+		if value, ok := os.LookupEnv(name); ok {
+			return value, true
 		}
+	}
 
-		// Variable is between braces: ${VARIABLE}. Continue ignoring braces.
-		if atVar && (ip.rawValue.isOpenBrace() || ip.rawValue.isCloseBrace()) {
-			continue
+	for _, lookup := range ip.lookups {
+		if value, ok := lookup(name); ok {
+			return value, true
 		}
+	}
+
+	return "", false
+}
+
+// fail records the error of a variable which cannot be interpolated.
+func (ip *Interpolator) fail(key string, err error) {
+	ip.failed[key] = true
+
+	if _, exists := ip.errs[key]; !exists {
+		ip.errs[key] = err
+	}
+}
+
+func containsVars(text string) bool {
+	return strings.IndexByte(text, '$') != -1
+}
+
+// parseVars interpolates the raw value. It reports false if the value cannot be interpolated.
+//
+// Variables are either between matching braces (`${VAR}`, `${VAR:-default}`) or bare (`$VAR`), the name of a bare
+// variable ending at the first character which is not a letter, a digit or an underscore (`$HOST:$PORT`).
+// A dollar sign not followed by a variable (`$`, `$-`, `${VAR`) is kept literally.
+func (s *scanner) parseVars() bool {
+	s.interpolatedVar.value = nil
+
+	for s.rawValue.i = 0; s.rawValue.i < len(s.rawValue.content); s.rawValue.i++ {
+		if s.varStarts() {
+			// Variable is between matching braces: ${VARIABLE:-default}. Expand it as a whole.
+			if end := s.rawValue.closingBrace(); end != -1 {
+				expanded, ok := s.expand(string(s.rawValue.content[s.rawValue.i+2 : end]))
+				if !ok {
+					return false
+				}
+
+				s.interpolatedVar.value = append(s.interpolatedVar.value, []rune(expanded)...)
+				s.rawValue.i = end
 
-		if !atVar {
-			if ip.rawValue.nextVarIsDoubleEscaped() {
-				ip.appendCurrentCharacterToNewValue()
 				continue
 			}
 
-			if ip.rawValue.nextVarIsEscaped() {
+			// Variable is bare: $VARIABLE.
+			if end := s.rawValue.nameEnd(); end > s.rawValue.i+1 {
+				if !s.appendAllToNewValue(string(s.rawValue.content[s.rawValue.i+1 : end])) {
+					return false
+				}
+
+				s.rawValue.i = end - 1
+
 				continue
 			}
 
-			// Append literal.
-			ip.appendCurrentCharacterToNewValue()
+			// Not a variable. Append literal.
+			s.appendCurrentCharacterToNewValue()
 
 			continue
 		}
 
-		// Variable ends when a space is found. Append literal.
-		if ip.rawValue.isAtSpace() {
-			ip.appendAllToNewValue()
-			ip.appendCurrentCharacterToNewValue()
-			ip.interpolatedVar.name = nil
-			atVar = false
+		if s.rawValue.nextVarIsDoubleEscaped() && s.referenceAt(2) { //nolint: mnd
+			s.appendCurrentCharacterToNewValue()
+			continue
+		}
 
+		if s.rawValue.nextVarIsEscaped() && s.referenceAt(1) {
 			continue
 		}
 
-		ip.appendToName()
+		// Append literal.
+		s.appendCurrentCharacterToNewValue()
 	}
 
-	if atVar {
-		ip.appendAllToNewValue()
+	return true
+}
+
+// expand evaluates the expression between the braces of a variable: `NAME`, `NAME:-default` etc.
+// It reports false if the expression cannot be evaluated.
+func (s *scanner) expand(expression string) (string, bool) {
+	name, operator, word := splitExpression(expression)
+
+	value, set, ok := s.ip.lookup(name, s.key)
+	if !ok {
+		return "", false
 	}
+
+	switch operator {
+	case ":-":
+		if value == "" {
+			return s.interpolateWord(word)
+		}
+	case "-":
+		if !set {
+			return s.interpolateWord(word)
+		}
+	case ":?":
+		if value == "" {
+			return "", s.failUnset(name, word)
+		}
+	case "?":
+		if !set {
+			return "", s.failUnset(name, word)
+		}
+	case ":+":
+		if value == "" {
+			return "", true
+		}
+
+		return s.interpolateWord(word)
+	case "+":
+		if !set {
+			return "", true
+		}
+
+		return s.interpolateWord(word)
+	default:
+		if !set {
+			return s.undefined(name, "${"+expression+"}"), true
+		}
+	}
+
+	return value, true
 }
 
-func (ip *Interpolator) varStarts() bool {
-	// Litteral dollar sign at the end: `text $`.
-	if isDolar(ip.rawValue.current()) && ip.rawValue.atEnd() {
+// undefined handles the reference to a variable which is not set, according to the undefined policy,
+// and returns the text replacing the reference.
+func (s *scanner) undefined(name, reference string) string {
+	switch s.ip.undefinedPolicy {
+	case UndefinedError:
+		if !slices.Contains(s.ip.undefined[s.key], name) {
+			s.ip.undefined[s.key] = append(s.ip.undefined[s.key], name)
+		}
+
+		return ""
+	case UndefinedKeep:
+		return reference
+	default:
+		return ""
+	}
+}
+
+// failUnset records the error of a variable required by `${VAR:?message}`, with the interpolated message.
+// It always reports false.
+func (s *scanner) failUnset(name, word string) bool {
+	message, ok := s.interpolateWord(word)
+	if !ok {
 		return false
 	}
 
+	if message == "" {
+		message = "parameter null or not set"
+	}
+
+	s.ip.fail(s.key, fmt.Errorf("%s: %s", name, message))
+
+	return false
+}
+
+// interpolateWord interpolates the word of an expansion (`default` of `${VAR:-default}`).
+func (s *scanner) interpolateWord(word string) (string, bool) {
+	if !containsVars(word) {
+		return word, true
+	}
+
+	wordScanner := &scanner{ip: s.ip, key: s.key, rawValue: value{content: []rune(word)}}
+
+	if !wordScanner.parseVars() {
+		return "", false
+	}
+
+	return string(wordScanner.interpolatedVar.value), true
+}
+
+// splitExpression separates the name, the operator and the word of an expression (`NAME:-word`).
+// The name is made of letters, digits, underscores and dots. If no operator follows the name,
+// the whole expression is the name.
+func splitExpression(expression string) (name, operator, word string) {
+	end := strings.IndexFunc(expression, func(r rune) bool {
+		return !isNameCharacter(r) && r != '.'
+	})
+	if end == -1 {
+		return expression, "", ""
+	}
+
+	for _, op := range operators {
+		if strings.HasPrefix(expression[end:], op) {
+			return expression[:end], op, expression[end+len(op):]
+		}
+	}
+
+	return expression, "", ""
+}
+
+func isNameCharacter(r rune) bool {
+	return r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+}
+
+// referenceAt reports whether the dollar sign at the given steps from the current position can start a variable:
+// any one, or only one followed by a brace if only braced variables are interpolated.
+func (s *scanner) referenceAt(steps int) bool {
+	return !s.ip.bracedOnly || s.rawValue.peek(steps+1) == '{'
+}
+
+func (s *scanner) varStarts() bool {
 	// Normal variable: `text $VAR text`. Will use its value.
-	if !isDolar(ip.rawValue.current()) {
+	if !isDolar(s.rawValue.current()) || !s.referenceAt(0) {
 		return false
 	}
 
 	// Variable is double escaped: `text \\$VAR text`. The escape character is actually escaped.
 	// Will use an escape character and variable's value.
-	if isEscape(ip.rawValue.peek(-2)) && isEscape(ip.rawValue.peek(-1)) {
+	if isEscape(s.rawValue.peek(-2)) && isEscape(s.rawValue.peek(-1)) {
 		return true
 	}
 
 	// Variable is escaped: `text \$VAR text`. Actually not a variable. Will use it literally.
-	if isEscape(ip.rawValue.peek(-1)) {
-		return false
-	}
+	return !isEscape(s.rawValue.peek(-1))
+}
+
+func (s *scanner) appendCurrentCharacterToNewValue() {
+	s.interpolatedVar.value = append(s.interpolatedVar.value, s.rawValue.current())
+}
 
-	next := ip.rawValue.peek(1)
-	if unicode.IsSpace(next) || next == '"' || next == '\'' {
+// appendAllToNewValue appends the value of the named variable. It reports false if the variable cannot be interpolated.
+func (s *scanner) appendAllToNewValue(name string) bool {
+	value, set, ok := s.ip.lookup(name, s.key)
+	if !ok {
 		return false
 	}
 
-	return true
-}
-
-func (ip *Interpolator) appendToName() {
-	ip.interpolatedVar.name = append(ip.interpolatedVar.name, ip.rawValue.current())
-}
+	if !set {
+		value = s.undefined(name, "$"+name)
+	}
 
-func (ip *Interpolator) appendCurrentCharacterToNewValue() {
-	ip.interpolatedVar.value = append(ip.interpolatedVar.value, ip.rawValue.current())
-}
+	s.interpolatedVar.value = append(s.interpolatedVar.value, []rune(value)...)
 
-func (ip *Interpolator) appendAllToNewValue() {
-	ip.interpolatedVar.value = append(ip.interpolatedVar.value, []rune(ip.vars[string(ip.interpolatedVar.name)])...)
+	return true
 }
 
 func isEscape(r rune) bool {
@@ -197,6 +599,14 @@ func isDolar(r rune) bool {
 	return r == '$'
 }
 
-func New() *Interpolator {
-	return &Interpolator{}
+// New creates an Interpolator configured with the given options.
+// By default, variables not found in the map are looked up in the environment.
+func New(opts ...Option) *Interpolator {
+	ip := &Interpolator{env: true}
+
+	for _, opt := range opts {
+		opt(ip)
+	}
+
+	return ip
 }
@@ -1,6 +1,7 @@
 package interpolator_test
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/andreiavrammsd/config/internal/interpolator"
@@ -16,7 +17,9 @@ func assertEqual(t *testing.T, actual, expected string) {
 
 func TestInterpolate(t *testing.T) {
 	vars := testdata()
-	interpolator.New().Interpolate(vars)
+	if err := interpolator.New().Interpolate(vars); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
 
 	assertEqual(t, vars["TIMEOUT"], "2000000000")
 	assertEqual(t, vars["ABC"], " string\\\" ")
@@ -43,7 +46,7 @@ func Benchmark_Interpolate(b *testing.B) {
 	b.ResetTimer()
 
 	for range b.N {
-		interpolator.Interpolate(vars)
+		_ = interpolator.Interpolate(vars)
 	}
 }
 
@@ -59,12 +62,16 @@ func FuzzInterpolate(f *testing.F) {
 		varsFirst := map[string]string{
 			input: input,
 		}
-		fuzzInterpolator.Interpolate(varsFirst)
+		errFirst := fuzzInterpolator.Interpolate(varsFirst)
 
 		varsSecond := map[string]string{
 			input: input,
 		}
-		fuzzInterpolator.Interpolate(varsSecond)
+		errSecond := fuzzInterpolator.Interpolate(varsSecond)
+
+		if (errFirst == nil) != (errSecond == nil) {
+			t.Errorf("Before: %v, after: %v", errFirst, errSecond)
+		}
 
 		for key, firstValue := range varsFirst {
 			secondValue := varsSecond[key]
@@ -76,6 +83,206 @@ func FuzzInterpolate(f *testing.F) {
 	})
 }
 
+func TestInterpolateWithParameterExpansion(t *testing.T) {
+	vars := map[string]string{
+		"SET":        "value",
+		"EMPTY":      "",
+		"DEFAULT":    "${UNSET:-default} ${EMPTY:-default} ${SET:-default}",
+		"UNSET_ONLY": "${UNSET-default} ${EMPTY-default} ${SET-default}",
+		"ALT":        "${UNSET:+alt} ${EMPTY:+alt} ${SET:+alt}",
+		"ALT_SET":    "${UNSET+alt} ${EMPTY+alt} ${SET+alt}",
+		"NESTED":     "${UNSET:-${SET}-$SET} ${UNSET:-{braces\\}}",
+		"REQUIRED":   "${SET:?error} ${EMPTY?error}",
+		"DOTTED":     "${section.key}",
+	}
+	vars["section.key"] = "dotted"
+
+	if err := interpolator.New().Interpolate(vars); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	assertEqual(t, vars["DEFAULT"], "default default value")
+	assertEqual(t, vars["UNSET_ONLY"], "default  value")
+	assertEqual(t, vars["ALT"], "  alt")
+	assertEqual(t, vars["ALT_SET"], " alt alt")
+	assertEqual(t, vars["NESTED"], "value-value {braces\\}")
+	assertEqual(t, vars["REQUIRED"], "value ")
+	assertEqual(t, vars["DOTTED"], "dotted")
+}
+
+func TestInterpolateWithRequiredVariables(t *testing.T) {
+	vars := map[string]string{
+		"EMPTY": "",
+		"A":     "${UNSET:?A needs $EMPTY_MSG value}",
+		"B":     "${EMPTY:?}",
+		"C":     "${UNSET?}",
+		"D":     "${EMPTY?not reported}",
+	}
+
+	err := interpolator.New().Interpolate(vars)
+
+	var interpolationErr *interpolator.Error
+	if !errors.As(err, &interpolationErr) {
+		t.Fatal("expected interpolation error:", err)
+	}
+
+	assertEqual(t, interpolationErr.Key, "A")
+	assertEqual(t, err.Error(), `interpolate A: UNSET: A needs  value
+interpolate B: EMPTY: parameter null or not set
+interpolate C: UNSET: parameter null or not set`)
+}
+
+func TestInterpolateInDependencyOrder(t *testing.T) {
+	for range 10 {
+		vars := map[string]string{
+			"A": "$Z",
+			"B": "$A ${C}",
+			"C": "\\$A",
+			"Y": "1",
+			"Z": "${Y}$Y",
+		}
+
+		if err := interpolator.New().Interpolate(vars); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		assertEqual(t, vars["A"], "11")
+		assertEqual(t, vars["B"], "11 $A")
+		assertEqual(t, vars["C"], "$A")
+		assertEqual(t, vars["Z"], "11")
+	}
+}
+
+func TestInterpolateWithCycles(t *testing.T) {
+	vars := map[string]string{
+		"A":    "$B",
+		"B":    "${C:-x}",
+		"C":    "$A",
+		"SELF": "${SELF}",
+		"D":    "$A",
+		"E":    "ok",
+	}
+
+	err := interpolator.New().Interpolate(vars)
+
+	if !errors.Is(err, interpolator.ErrCycle) {
+		t.Fatal("expected cycle error:", err)
+	}
+
+	assertEqual(t, err.Error(), `interpolate A: reference cycle: A -> B -> C -> A
+interpolate SELF: reference cycle: SELF -> SELF`)
+	assertEqual(t, vars["A"], "$B")
+	assertEqual(t, vars["D"], "$A")
+	assertEqual(t, vars["E"], "ok")
+}
+
+func TestInterpolateWithLookups(t *testing.T) {
+	t.Setenv("INTERPOLATOR_TEST_USER", "env_user")
+	t.Setenv("INTERPOLATOR_TEST_HOST", "env_host")
+	t.Setenv("INTERPOLATOR_TEST_PATH", "/usr/bin")
+
+	custom := func(name string) (string, bool) {
+		if name == "INTERPOLATOR_TEST_PORT" || name == "INTERPOLATOR_TEST_USER" {
+			return "custom_" + name, true
+		}
+
+		return "", false
+	}
+
+	newVars := func() map[string]string {
+		return map[string]string{
+			"INTERPOLATOR_TEST_HOST": "file_host",
+			"URL":                    "${INTERPOLATOR_TEST_USER}@${INTERPOLATOR_TEST_HOST}:${INTERPOLATOR_TEST_PORT}",
+			"INTERPOLATOR_TEST_PATH": "${INTERPOLATOR_TEST_PATH}:/bin",
+		}
+	}
+
+	vars := newVars()
+	if err := interpolator.New(interpolator.WithLookup(custom)).Interpolate(vars); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	assertEqual(t, vars["URL"], "env_user@file_host:custom_INTERPOLATOR_TEST_PORT")
+	assertEqual(t, vars["INTERPOLATOR_TEST_PATH"], "/usr/bin:/bin")
+
+	vars = newVars()
+	err := interpolator.New(interpolator.WithoutEnv(), interpolator.WithLookup(custom)).Interpolate(vars)
+
+	if !errors.Is(err, interpolator.ErrCycle) {
+		t.Fatal("expected cycle error:", err)
+	}
+
+	assertEqual(t, vars["URL"], "custom_INTERPOLATOR_TEST_USER@file_host:custom_INTERPOLATOR_TEST_PORT")
+}
+
+func TestInterpolateWithUndefinedVariables(t *testing.T) {
+	newVars := func() map[string]string {
+		return map[string]string{
+			"A":       "1",
+			"Q":       "$UNDEFINED_VAR",
+			"URL":     "${UNDEFINED_HOST}:${A} ${UNDEFINED_HOST} ${UNDEFINED_PORT:-80} ${UNDEFINED_PATH:+/path}",
+			"DEFAULT": "${UNDEFINED_PORT:-$UNDEFINED_DEFAULT}",
+			"REF":     "$URL",
+		}
+	}
+
+	vars := newVars()
+	if err := interpolator.New(interpolator.WithoutEnv()).Interpolate(vars); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	assertEqual(t, vars["Q"], "")
+	assertEqual(t, vars["URL"], ":1  80 ")
+
+	vars = newVars()
+	if err := interpolator.New(interpolator.WithoutEnv(), interpolator.WithUndefined(interpolator.UndefinedKeep)).
+		Interpolate(vars); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	assertEqual(t, vars["Q"], "$UNDEFINED_VAR")
+	assertEqual(t, vars["URL"], "${UNDEFINED_HOST}:1 ${UNDEFINED_HOST} 80 ")
+	assertEqual(t, vars["DEFAULT"], "$UNDEFINED_DEFAULT")
+
+	vars = newVars()
+	err := interpolator.New(interpolator.WithoutEnv(), interpolator.WithUndefined(interpolator.UndefinedError)).
+		Interpolate(vars)
+
+	if !errors.Is(err, interpolator.ErrUndefined) {
+		t.Fatal("expected undefined error:", err)
+	}
+
+	assertEqual(t, err.Error(), `interpolate DEFAULT: undefined variables: UNDEFINED_DEFAULT
+interpolate Q: undefined variables: UNDEFINED_VAR
+interpolate URL: undefined variables: UNDEFINED_HOST`)
+	assertEqual(t, vars["REF"], "$URL")
+}
+
+func TestInterpolateWithVariableNames(t *testing.T) {
+	vars := map[string]string{
+		"A":       "1",
+		"B":       "2",
+		"C":       "3",
+		"HOST":    "localhost",
+		"PORT":    "8080",
+		"R":       "$A-$B-$C",
+		"URL":     "http://$HOST:$PORT/path?a=$A&b=${B}",
+		"BRACES":  "${A}} {$A} ${A",
+		"DOLLARS": "$ $- $$ ${}",
+		"UNICODE": "ünï-$A€ ${B}ü $C日本",
+	}
+
+	if err := interpolator.New().Interpolate(vars); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	assertEqual(t, vars["R"], "1-2-3")
+	assertEqual(t, vars["URL"], "http://localhost:8080/path?a=1&b=2")
+	assertEqual(t, vars["BRACES"], "1} {1} ${A")
+	assertEqual(t, vars["DOLLARS"], "$ $- $$ ")
+	assertEqual(t, vars["UNICODE"], "ünï-1€ 2ü 3日本")
+}
+
 func testdata() map[string]string {
 	vars := make(map[string]string)
 	vars["TIMEOUT"] = "2000000000"
@@ -95,3 +302,40 @@ func testdata() map[string]string {
 	vars["INTERPOLATED"] = "\\$B env_$A $ \\$B \\\\$C ${REDIS_PORT} + $"
 	return vars
 }
+
+func TestInterpolateWithBracedOnly(t *testing.T) {
+	vars := map[string]string{
+		"A":        "1",
+		"BRACED":   "${A} $A ${UNSET:-$A} pa$$word",
+		"ESCAPED":  "\\${A} \\$A \\\\${A}",
+		"DOTTED":   "${section.key}",
+		"DOLLARS":  "$ ${ $A$",
+		"NO_BRACE": "$A",
+	}
+	vars["section.key"] = "dotted"
+
+	if err := interpolator.New(interpolator.WithBracedOnly()).Interpolate(vars); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	assertEqual(t, vars["BRACED"], "1 $A $A pa$$word")
+	assertEqual(t, vars["ESCAPED"], "${A} \\$A \\1")
+	assertEqual(t, vars["DOTTED"], "dotted")
+	assertEqual(t, vars["DOLLARS"], "$ ${ $A$")
+	assertEqual(t, vars["NO_BRACE"], "$A")
+}
+
+func TestInterpolateExceptLiterals(t *testing.T) {
+	vars := map[string]string{
+		"A":       "1",
+		"LITERAL": "cost $5 \\$A ${A}",
+		"REF":     "${LITERAL} $A",
+	}
+
+	if err := interpolator.New().InterpolateExcept(vars, map[string]bool{"LITERAL": true}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	assertEqual(t, vars["LITERAL"], "cost $5 \\$A ${A}")
+	assertEqual(t, vars["REF"], "cost $5 \\$A ${A} 1")
+}
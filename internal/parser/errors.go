@@ -0,0 +1,25 @@
+package parser
+
+// ParseError is a syntax error found in the input.
+type ParseError struct {
+	File   string
+	Line   int
+	Column int
+	Msg    string
+
+	// Err is the underlying error, if any.
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	position := Position{File: e.File, Line: e.Line, Column: e.Column}.String()
+	if position == "" {
+		return e.Msg
+	}
+
+	return position + ": " + e.Msg
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
@@ -3,75 +3,233 @@ package parser
 import (
 	"bufio"
 	"errors"
+	"fmt"
 	"io"
+	"strconv"
 	"strings"
 )
 
+const (
+	// Number of hexadecimal digits of an `\uXXXX` escape sequence.
+	unicodeEscapeLength = 4
+
+	// Shell keywords allowed before names (`export NAME=value`) and shell commands allowed as lines (`set -a`),
+	// so files can be sourced by shell too.
+	exportKeyword = "export"
+	setKeyword    = "set"
+)
+
 type tokens struct {
 	name  token
 	value token
+
+	// Value is quoted, so it is kept as is, without trimming spaces.
+	quoted bool
+
+	// Value is single-quoted, so it is not interpolated.
+	literal bool
+
+	// Name is followed by spaces, so it cannot continue.
+	nameEnded bool
+
+	// Name is preceded by the export keyword.
+	exported bool
+
+	// Line and column where the name starts.
+	line   int
+	column int
+
+	// Line and column of the opening quote of the value.
+	quoteLine   int
+	quoteColumn int
 }
 
+// Position is the location of a variable in the input.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+// String formats the position as `file:line:column`, or `line L, column C` if the file is not known.
+func (p Position) String() string {
+	switch {
+	case p.File != "" && p.Line > 0:
+		return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+	case p.File != "":
+		return p.File
+	case p.Line > 0:
+		return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+	default:
+		return ""
+	}
+}
+
+// DuplicatePolicy is the handling of a key defined more than once, in the same input or in previous ones.
+type DuplicatePolicy byte
+
+const (
+	// DuplicateLastWins keeps the last value of a key.
+	DuplicateLastWins DuplicatePolicy = iota
+
+	// DuplicateFirstWins keeps the first value of a key.
+	DuplicateFirstWins
+
+	// DuplicateError reports a key defined more than once as *ParseError, naming both locations.
+	DuplicateError
+)
+
 type Parser struct {
 	vars         map[string]string
+	positions    map[string]Position
+	literals     map[string]bool
+	file         string
 	stream       stream
 	tokens       tokens
 	currentToken tokenKind
+
+	// Previous rune of a double-quoted value is an escape character.
+	escaped bool
+
+	// Comments begin anywhere outside quotes, not only at line start or after whitespace.
+	legacyComments bool
+
+	// Malformed lines are reported as errors instead of being skipped.
+	strictSyntax bool
+
+	// Handling of keys defined more than once.
+	duplicates DuplicatePolicy
+
+	// Values are not interpolated, so escaped backslashes before dollar signs are not kept for the interpolator.
+	noInterpolation bool
+
+	// Syntax errors found in strict syntax mode.
+	errs []error
+}
+
+// Option configures the Parser.
+type Option func(*Parser)
+
+// WithLegacyComments makes comments begin at any `#` outside quotes (`name=value#COMMENT`),
+// instead of only at line start or after whitespace.
+func WithLegacyComments() Option {
+	return func(p *Parser) {
+		p.legacyComments = true
+	}
+}
+
+// WithStrictSyntax makes malformed input fail with *ParseError instead of being skipped or accepted:
+// lines without `=`, invalid keys (`$KEY`, `1KEY`), unterminated quoted values,
+// text after a quoted value or after a key.
+func WithStrictSyntax() Option {
+	return func(p *Parser) {
+		p.strictSyntax = true
+	}
+}
+
+// WithDuplicates sets the handling of keys defined more than once (DuplicateLastWins by default).
+// Keys of previous inputs parsed into the same vars map are also considered.
+func WithDuplicates(policy DuplicatePolicy) Option {
+	return func(p *Parser) {
+		p.duplicates = policy
+	}
+}
+
+// WithoutInterpolation processes escaped backslashes before dollar signs in double-quoted values like any other
+// (`\\$` is `\$`), instead of keeping them for the interpolator.
+func WithoutInterpolation() Option {
+	return func(p *Parser) {
+		p.noInterpolation = true
+	}
 }
 
 // Parse consumes a reader and detects variables that it will add to the passed vars map.
-func (p *Parser) Parse(r io.Reader, vars map[string]string) error {
+// If positions is not nil, the position of each variable is added to it.
+// If literals is not nil, the keys of single-quoted values, which must not be interpolated, are added to it.
+// If the reader has a name (like *os.File), it is used as file of the positions.
+//
+// In strict syntax mode, malformed lines are reported as *ParseError, all of them joined in the returned error.
+func (p *Parser) Parse(
+	r io.Reader, vars map[string]string, positions map[string]Position, literals map[string]bool,
+) error {
 	p.vars = vars
+	p.positions = positions
+	p.literals = literals
+	p.file = ""
 	p.stream = stream{reader: bufio.NewReader(r)}
 	p.tokens = tokens{}
 	p.currentToken = nameToken
+	p.escaped = false
+	p.errs = nil
+
+	if named, ok := r.(interface{ Name() string }); ok {
+		p.file = named.Name()
+	}
 
 	for {
 		err := p.stream.advance()
 
 		switch {
 		case errors.Is(err, io.EOF):
-			// Parsing done, save last variable.
-			if p.atToken(valueToken) {
-				p.saveVar()
-			}
+			p.end()
 
-			return nil
+			return errors.Join(p.errs...)
 
 		case err != nil:
 			return err
 
-		case p.stream.isAtEqualSign() && !p.atToken(valueToken):
-			// If equal sign detected and not already scanning variable value
-			// (equal sign detected first time on line),
-			// the variable value starts (`name=VALUE #comment`).
-			p.setToken(valueToken)
+		case p.atToken(doubleQuotedValueToken):
+			// Read double-quoted value, which can span multiple lines (`name="VALUE"`).
+			p.readDoubleQuotedValue()
+
+		case p.atToken(singleQuotedValueToken):
+			// Read single-quoted value, which can span multiple lines (`name='VALUE'`).
+			p.readSingleQuotedValue()
 
-		case p.stream.isAtCommentBegin():
-			// Comment begins (`name=value #COMMENT`), save last variable.
-			if p.atToken(valueToken) {
-				p.saveVar()
+		case p.atToken(valueToken) && (p.stream.isAtDoubleQuote() || p.stream.isAtSingleQuote()) &&
+			p.tokens.value.isBlank():
+			// Quoted value begins (`name="value"`).
+			p.tokens.value.reset()
+			p.tokens.quoted = true
+			p.tokens.quoteLine, p.tokens.quoteColumn = p.stream.line, p.stream.column
+
+			if p.stream.isAtDoubleQuote() {
+				p.setToken(doubleQuotedValueToken)
+			} else {
+				p.tokens.literal = true
+				p.setToken(singleQuotedValueToken)
 			}
 
+		case p.stream.isAtEqualSign() && p.atToken(nameToken):
+			// If equal sign detected while scanning variable name
+			// (equal sign detected first time on line),
+			// the variable value starts (`name=VALUE #comment`).
+			p.startValue()
+
+		case p.stream.isAtCommentBegin() && (p.legacyComments || p.stream.isAfterSpace()):
+			// Comment begins at line start or after whitespace (`name=value #COMMENT`), save last variable.
+			// Otherwise, it is part of the name or value (`name=va#lue`).
+			p.endLine()
 			p.setToken(commentToken)
 
 		case p.stream.isAtLineEnd():
 			// End of line reached, save last variable.
-			if p.atToken(valueToken) {
-				p.saveVar()
-			}
-
+			p.endLine()
 			p.setToken(nameToken)
 
 		case p.atToken(commentToken):
 			// If inside a comment, just skip to next rune.
 
-		case p.atToken(nameToken):
-			// Read variable name ignoring spaces (`NAME=value #comment`).
+		case p.atToken(quotedValueEndToken):
+			// After a quoted value, skip to next rune (`name="value" IGNORED`).
 			if !p.stream.isAtSpace() {
-				p.tokens.name.append(p.stream.current)
+				p.fail(p.stream.line, p.stream.column, "unexpected text after quoted value")
 			}
 
+		case p.atToken(nameToken):
+			// Read variable name ignoring spaces (`NAME=value #comment`).
+			p.readName()
+
 		case p.atToken(valueToken):
 			// Read variable value (`name=VALUE #comment`).
 			p.tokens.value.append(p.stream.current)
@@ -79,16 +237,255 @@ func (p *Parser) Parse(r io.Reader, vars map[string]string) error {
 	}
 }
 
+// readName appends the current rune to the variable name, ignoring spaces.
+// A leading export keyword is dropped (`export NAME=value`) and set commands (`set -a`) are skipped.
+// In strict syntax mode, a name cannot contain spaces.
+func (p *Parser) readName() {
+	if p.stream.isAtSpace() {
+		p.tokens.nameEnded = len(p.tokens.name) > 0
+		return
+	}
+
+	if p.tokens.nameEnded {
+		switch string(p.tokens.name) {
+		case exportKeyword:
+			// Variable is exported for shell (`export NAME=value`), the keyword is dropped.
+			p.tokens.name.reset()
+			p.tokens.nameEnded = false
+			p.tokens.exported = true
+		case setKeyword:
+			// Shell command (`set -a`) is skipped.
+			p.resetTokens()
+			p.setToken(commentToken)
+
+			return
+		default:
+			if p.fail(p.stream.line, p.stream.column, "unexpected text after key") {
+				return
+			}
+		}
+	}
+
+	if len(p.tokens.name) == 0 {
+		p.tokens.line, p.tokens.column = p.stream.line, p.stream.column
+	}
+
+	p.tokens.name.append(p.stream.current)
+}
+
+// startValue begins scanning the variable value.
+// In strict syntax mode, the name must be a valid key.
+func (p *Parser) startValue() {
+	switch {
+	case len(p.tokens.name) == 0:
+		if p.fail(p.stream.line, p.stream.column, "missing key") {
+			return
+		}
+	case !isValidKey(p.tokens.name):
+		if p.fail(p.tokens.line, p.tokens.column, fmt.Sprintf("invalid key %q", string(p.tokens.name))) {
+			return
+		}
+	}
+
+	p.setToken(valueToken)
+}
+
+// endLine saves the variable of the line (if any) when the line ends.
+// A name without value is dropped. In strict syntax mode, it is reported as missing the equal sign.
+func (p *Parser) endLine() {
+	switch {
+	case p.atToken(valueToken):
+		p.saveVar()
+	case p.atToken(nameToken) && p.tokens.exported:
+		// Shell export of an existing variable (`export NAME`) is skipped.
+		p.resetTokens()
+	case p.atToken(nameToken) && len(p.tokens.name) > 0:
+		p.fail(p.tokens.line, p.tokens.column, fmt.Sprintf("missing '=' after key %q", string(p.tokens.name)))
+		p.resetTokens()
+	}
+}
+
+// end saves the last variable when the input ends.
+// A value without its closing quote is kept. In strict syntax mode, it is reported as unterminated.
+func (p *Parser) end() {
+	if p.atToken(doubleQuotedValueToken) || p.atToken(singleQuotedValueToken) {
+		if p.fail(p.tokens.quoteLine, p.tokens.quoteColumn, "unterminated quoted value") {
+			return
+		}
+
+		p.saveVar()
+
+		return
+	}
+
+	p.endLine()
+}
+
+// fail records a syntax error in strict syntax mode and skips the rest of the line.
+// It reports whether the error was recorded.
+func (p *Parser) fail(line, column int, msg string) bool {
+	if !p.strictSyntax {
+		return false
+	}
+
+	p.errs = append(p.errs, &ParseError{File: p.file, Line: line, Column: column, Msg: msg})
+	p.resetTokens()
+	p.setToken(commentToken)
+
+	return true
+}
+
+// isValidKey reports whether the key starts with a letter or underscore,
+// followed by letters, digits, underscores, dots or dashes.
+func isValidKey(key []rune) bool {
+	for i, r := range key {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case i > 0 && (r >= '0' && r <= '9' || r == '.' || r == '-'):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// readDoubleQuotedValue appends the current rune to a double-quoted value, processing escape sequences:
+// `\n`, `\r`, `\t`, `\"`, `\\`, `\uXXXX`. Escaped dollar signs (`\$`, `\\$`) are left for interpolation,
+// unless values are not interpolated.
+// Unknown escape sequences are kept as they are.
+func (p *Parser) readDoubleQuotedValue() {
+	switch {
+	case p.escaped:
+		p.escaped = false
+		p.appendEscaped()
+
+	case p.stream.isAtEscape():
+		p.escaped = true
+
+	case p.stream.isAtDoubleQuote():
+		p.saveVar()
+		p.setToken(quotedValueEndToken)
+
+	case p.stream.current == '\r':
+		// Line ends are normalized to `\n`.
+
+	default:
+		p.tokens.value.append(p.stream.current)
+	}
+}
+
+// appendEscaped appends the rune escaped by the previous backslash.
+func (p *Parser) appendEscaped() {
+	switch p.stream.current {
+	case 'n':
+		p.tokens.value.append('\n')
+	case 'r':
+		p.tokens.value.append('\r')
+	case 't':
+		p.tokens.value.append('\t')
+	case '"':
+		p.tokens.value.append('"')
+	case '\\':
+		// Keep an escaped backslash before a dollar sign, so the interpolator can tell it from `\$`.
+		if next := p.stream.peek(1); len(next) == 1 && next[0] == '$' && !p.noInterpolation {
+			p.tokens.value.append('\\')
+		}
+
+		p.tokens.value.append('\\')
+	case 'u':
+		if code, err := strconv.ParseUint(string(p.stream.peek(unicodeEscapeLength)), 16, 32); err == nil {
+			p.stream.skip(unicodeEscapeLength)
+			p.tokens.value.append(rune(code))
+
+			return
+		}
+
+		p.tokens.value.append('\\')
+		p.tokens.value.append(p.stream.current)
+	default:
+		p.tokens.value.append('\\')
+		p.tokens.value.append(p.stream.current)
+	}
+}
+
+// readSingleQuotedValue appends the current rune to a single-quoted value, which is kept literally.
+func (p *Parser) readSingleQuotedValue() {
+	switch {
+	case p.stream.isAtSingleQuote():
+		p.saveVar()
+		p.setToken(quotedValueEndToken)
+
+	case p.stream.current == '\r':
+		// Line ends are normalized to `\n`.
+
+	default:
+		p.tokens.value.append(p.stream.current)
+	}
+}
+
 // saveVar stores the variable name and its value,
 // and sets tokens to start scanning for a new variable.
 func (p *Parser) saveVar() {
-	if len(p.tokens.name) > 0 {
-		p.vars[string(p.tokens.name)] = cleanVarValue(p.tokens.value)
+	if len(p.tokens.name) > 0 && p.keepVar() {
+		name := string(p.tokens.name)
+
+		if p.tokens.quoted {
+			p.vars[name] = string(p.tokens.value)
+		} else {
+			p.vars[name] = strings.TrimSpace(string(p.tokens.value))
+		}
+
+		if p.positions != nil {
+			p.positions[name] = Position{File: p.file, Line: p.tokens.line, Column: p.tokens.column}
+		}
+
+		if p.literals != nil {
+			if p.tokens.literal {
+				p.literals[name] = true
+			} else {
+				delete(p.literals, name)
+			}
+		}
 	}
 
+	p.resetTokens()
+	p.setToken(nameToken)
+}
+
+// keepVar reports whether the variable is stored, according to the duplicate policy.
+func (p *Parser) keepVar() bool {
+	name := string(p.tokens.name)
+
+	if _, exists := p.vars[name]; !exists {
+		return true
+	}
+
+	switch p.duplicates {
+	case DuplicateFirstWins:
+		return false
+	case DuplicateError:
+		msg := fmt.Sprintf("duplicate key %q", name)
+		if first := p.positions[name].String(); first != "" {
+			msg += fmt.Sprintf(" (first defined at %s)", first)
+		}
+
+		p.errs = append(p.errs, &ParseError{File: p.file, Line: p.tokens.line, Column: p.tokens.column, Msg: msg})
+
+		return false
+	default:
+		return true
+	}
+}
+
+func (p *Parser) resetTokens() {
 	p.tokens.name.reset()
 	p.tokens.value.reset()
-	p.setToken(nameToken)
+	p.tokens.quoted = false
+	p.tokens.literal = false
+	p.tokens.nameEnded = false
+	p.tokens.exported = false
+	p.escaped = false
 }
 
 func (p *Parser) atToken(kind tokenKind) bool {
@@ -99,10 +496,13 @@ func (p *Parser) setToken(kind tokenKind) {
 	p.currentToken = kind
 }
 
-func cleanVarValue(v []rune) string {
-	return strings.Trim(strings.TrimSpace(string(v)), `"'`)
-}
+// New creates a Parser configured with the given options.
+func New(opts ...Option) *Parser {
+	p := &Parser{}
+
+	for _, opt := range opts {
+		opt(p)
+	}
 
-func New() *Parser {
-	return &Parser{}
+	return p
 }
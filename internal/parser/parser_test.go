@@ -29,12 +29,12 @@ func TestParse(t *testing.T) {
 	reader := bytes.NewReader(testdata("testdata/.env"))
 	vars := make(map[string]string)
 
-	err := parser.New().Parse(reader, vars)
+	err := parser.New().Parse(reader, vars, nil, nil)
 	if err != nil {
 		t.Error("expected no error")
 	}
 
-	expectedNumberOfVars := 67 // IS THIS OK?
+	expectedNumberOfVars := 64 // IS THIS OK?
 	if len(vars) != expectedNumberOfVars {
 		t.Fatalf("Expected %d vars, got %d", expectedNumberOfVars, len(vars))
 	}
@@ -42,7 +42,7 @@ func TestParse(t *testing.T) {
 	assertVar(t, vars, "A", "1")
 	assertVar(t, vars, "B", "$A")
 	assertVar(t, vars, "BB", "CC")
-	assertVar(t, vars, "VAR_WITH_COMMENT", "val with comment")
+	assertVar(t, vars, "VAR_WITH_COMMENT", "val with comment# key=value")
 	assertVar(t, vars, "D", "")
 	assertVar(t, vars, "D2", "")
 	assertVar(t, vars, "D3", "")
@@ -52,9 +52,9 @@ func TestParse(t *testing.T) {
 	assertVar(t, vars, "MONGO_DATABASE_COLLECTION_NAME", "us=ers")
 	assertVar(t, vars, "G", "quote 'inside' quote")
 	assertVar(t, vars, "H", "quote \"inside\" quote")
-	assertVar(t, vars, "I", "line1\\nline2")
-	assertVar(t, vars, "J", "tab\\tseparated")
-	assertVar(t, vars, "ABC", " string\\\" ")
+	assertVar(t, vars, "I", "line1\nline2")
+	assertVar(t, vars, "J", "tab\tseparated")
+	assertVar(t, vars, "ABC", " string\" ")
 	assertVar(t, vars, "K", "Emoji 🚀 and Unicode ü")
 	assertVar(t, vars, "L", "spaced_key")
 	assertVar(t, vars, "M", "spaced_value")
@@ -63,8 +63,8 @@ func TestParse(t *testing.T) {
 	assertVar(t, vars, "NOT_NUM", "---1")
 	assertVar(t, vars, "POS_NUM", "+1")
 	assertVar(t, vars, "POS_NOT_NUM", "++1")
-	// FAILS: assertVar(t, vars, "O", "#notacomment")
-	assertVar(t, vars, "O2", "")
+	assertVar(t, vars, "O", "#notacomment")
+	assertVar(t, vars, "O2", "#notacomment\"")
 	assertVar(t, vars, "P", "key=value=another")
 	assertVar(t, vars, "Q", "$UNDEFINED_VAR")
 	assertVar(t, vars, "R", "$A-$B-$C")
@@ -103,15 +103,14 @@ func TestParse(t *testing.T) {
 	assertVar(t, vars, "EE", "[this looks like json]")
 	assertVar(t, vars, "EE2", "[this looks like json]")
 	assertVar(t, vars, "EE3", "[this looks like json]")
-	// IS THIS OK? assertVar(t, vars, "EE4", "[this looks like json]")
-	// IS THIS OK? assertVar(t, vars, "EE5", "[this looks like json]")
-	// FAILS: assertVar(t, vars, "FF", "{ \"name\": \"John\", \"age\": 30 }")
+	assertVar(t, vars, "EE4", "[this looks like json]\"\nEE5=\"[this looks like json]") // Quote closed on next line.
+	assertVar(t, vars, "FF", "{ \"name\": \"John\", \"age\": 30 }")
 	assertVar(t, vars, "ARRAY", "one,two,three")
 	assertVar(t, vars, "EMPTY1", "")
 	assertVar(t, vars, "EMPTY2", "")
 	assertVar(t, vars, "NUM_STRING", "12345")
-	// FAILS: assertVar(t, vars, "BROKEN_NEWLINE", "this is\nstill valid because quotes stay open")
-	// FAILS: assertVar(t, vars, "BROKEN_NEWLINE_SINGLE_QUOTES", "this is\nstill valid because quotes stay open")
+	assertVar(t, vars, "BROKEN_NEWLINE", "this is\nstill valid because quotes stay open")
+	assertVar(t, vars, "BROKEN_NEWLINE_SINGLE_QUOTES", "this is\nstill valid because quotes stay open")
 	assertVar(t, vars, "XX", "second")
 	assertVar(t, vars, "INTERPOLATED", "\\$B env_$A $ \\$B \\\\$C ${REDIS_PORT} + $")
 }
@@ -134,7 +133,7 @@ func (e *eofReader) Read(p []byte) (int, error) {
 
 func TestParseWithEOF(t *testing.T) {
 	vars := make(map[string]string)
-	err := parser.New().Parse(&eofReader{"a=b", 0}, vars)
+	err := parser.New().Parse(&eofReader{"a=b", 0}, vars, nil, nil)
 	if err != nil {
 		t.Error("expected no error")
 	}
@@ -142,6 +141,249 @@ func TestParseWithEOF(t *testing.T) {
 	assertVar(t, vars, "a", "b")
 }
 
+func TestParseWithPositions(t *testing.T) {
+	vars := make(map[string]string)
+	positions := make(map[string]parser.Position)
+
+	err := parser.New().Parse(bytes.NewReader([]byte("A=1\n\n# comment\n  B=2\nC=3")), vars, positions, nil)
+	if err != nil {
+		t.Fatal("expected no error")
+	}
+
+	expected := map[string]parser.Position{
+		"A": {Line: 1, Column: 1},
+		"B": {Line: 4, Column: 3},
+		"C": {Line: 5, Column: 1},
+	}
+
+	if !reflect.DeepEqual(positions, expected) {
+		t.Fatalf("incorrect positions: %v", positions)
+	}
+
+	file, err := os.Open("testdata/.env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if err := parser.New().Parse(file, vars, positions, nil); err != nil {
+		t.Fatal("expected no error")
+	}
+
+	if positions["A"] != (parser.Position{File: "testdata/.env", Line: 3, Column: 1}) {
+		t.Fatalf("incorrect position: %v", positions["A"])
+	}
+}
+
+func TestParseQuotedValues(t *testing.T) {
+	input := "DQ=\"a\\tb\\u00fc\\\\c\\\"d\\xe\"\r\n" +
+		"SQ='a\\tb $A \"c\"' # comment\n" +
+		"ESCAPED_VAR=\"\\$A \\\\$B\"\n" +
+		"MULTILINE=\"line1\r\nline2\"\n" +
+		"SPACED=\"  a b  \"  ignored\n" +
+		"UNTERMINATED=\"value"
+
+	vars := make(map[string]string)
+	literals := make(map[string]bool)
+
+	if err := parser.New().Parse(bytes.NewReader([]byte(input)), vars, nil, literals); err != nil {
+		t.Fatal("expected no error")
+	}
+
+	assertVar(t, vars, "DQ", "a\tb\u00fc\\c\"d\\xe")
+	assertVar(t, vars, "SQ", "a\\tb $A \"c\"")
+	assertVar(t, vars, "ESCAPED_VAR", "\\$A \\\\$B")
+	assertVar(t, vars, "MULTILINE", "line1\nline2")
+	assertVar(t, vars, "SPACED", "  a b  ")
+	assertVar(t, vars, "UNTERMINATED", "value")
+
+	if !reflect.DeepEqual(literals, map[string]bool{"SQ": true}) {
+		t.Fatalf("incorrect literals: %v", literals)
+	}
+
+	vars = make(map[string]string)
+	literals = map[string]bool{"ESCAPED_VAR": true}
+
+	if err := parser.New(parser.WithoutInterpolation()).Parse(
+		bytes.NewReader([]byte(input)), vars, nil, literals,
+	); err != nil {
+		t.Fatal("expected no error")
+	}
+
+	assertVar(t, vars, "SQ", "a\\tb $A \"c\"")
+	assertVar(t, vars, "ESCAPED_VAR", "\\$A \\$B")
+
+	if !reflect.DeepEqual(literals, map[string]bool{"SQ": true}) {
+		t.Fatalf("incorrect literals after redefinition: %v", literals)
+	}
+}
+
+func TestParseComments(t *testing.T) {
+	input := "# comment\n" +
+		"  # indented comment\n" +
+		"URL=http://x/#frag\n" +
+		"PASSWORD=ab#cd # comment\n" +
+		"HASH=#value\n" +
+		"EMPTY= # comment\n" +
+		"QUOTED=\"a # b\"#comment\n"
+
+	vars := make(map[string]string)
+
+	if err := parser.New().Parse(bytes.NewReader([]byte(input)), vars, nil, nil); err != nil {
+		t.Fatal("expected no error")
+	}
+
+	expected := map[string]string{
+		"URL":      "http://x/#frag",
+		"PASSWORD": "ab#cd",
+		"HASH":     "#value",
+		"EMPTY":    "",
+		"QUOTED":   "a # b",
+	}
+
+	if !reflect.DeepEqual(vars, expected) {
+		t.Fatalf("incorrect vars: %q", vars)
+	}
+
+	vars = make(map[string]string)
+
+	if err := parser.New(parser.WithLegacyComments()).Parse(bytes.NewReader([]byte(input)), vars, nil, nil); err != nil {
+		t.Fatal("expected no error")
+	}
+
+	expected = map[string]string{
+		"URL":      "http://x/",
+		"PASSWORD": "ab",
+		"HASH":     "",
+		"EMPTY":    "",
+		"QUOTED":   "a # b",
+	}
+
+	if !reflect.DeepEqual(vars, expected) {
+		t.Fatalf("incorrect legacy vars: %q", vars)
+	}
+}
+
+func TestParseWithStrictSyntax(t *testing.T) {
+	input := `A=1
+BROKEN_LINE
+$SPECIAL=weird
+1NUMBER=bad
+  =novalue
+SPACED KEY=value
+QUOTED="value" text
+AA.key=subvalue
+B="ü\u00fc" text
+# comment=1
+C="unterminated
+D=2`
+
+	vars := make(map[string]string)
+	err := parser.New(parser.WithStrictSyntax()).Parse(bytes.NewReader([]byte(input)), vars, nil, nil)
+
+	var parseErr *parser.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatal("expected parse error:", err)
+	}
+
+	if *parseErr != (parser.ParseError{Line: 2, Column: 1, Msg: "missing '=' after key \"BROKEN_LINE\""}) {
+		t.Fatalf("incorrect error: %#v", parseErr)
+	}
+
+	expected := `line 2, column 1: missing '=' after key "BROKEN_LINE"
+line 3, column 1: invalid key "$SPECIAL"
+line 4, column 1: invalid key "1NUMBER"
+line 5, column 3: missing key
+line 6, column 8: unexpected text after key
+line 7, column 16: unexpected text after quoted value
+line 9, column 13: unexpected text after quoted value
+line 11, column 3: unterminated quoted value`
+	if err.Error() != expected {
+		t.Fatal("incorrect error message:", err)
+	}
+
+	assertVar(t, vars, "A", "1")
+	assertVar(t, vars, "AA.key", "subvalue")
+
+	if len(vars) != 4 {
+		t.Fatalf("incorrect vars: %q", vars)
+	}
+}
+
+func TestParseWithExport(t *testing.T) {
+	input := `set -a
+export A=1
+export  B = "2"
+	export C='3' # comment
+export D
+export=4
+exportE=5
+set +a
+`
+
+	for _, p := range []*parser.Parser{parser.New(), parser.New(parser.WithStrictSyntax())} {
+		vars := make(map[string]string)
+
+		if err := p.Parse(bytes.NewReader([]byte(input)), vars, nil, nil); err != nil {
+			t.Fatal("expected no error:", err)
+		}
+
+		expected := map[string]string{
+			"A":       "1",
+			"B":       "2",
+			"C":       "3",
+			"export":  "4",
+			"exportE": "5",
+		}
+
+		if !reflect.DeepEqual(vars, expected) {
+			t.Fatalf("incorrect vars: %q", vars)
+		}
+	}
+}
+
+func TestParseWithDuplicates(t *testing.T) {
+	first := "A=1\nB=1\nA=2\n"
+	second := "B=2\nC=2\n"
+
+	parse := func(policy parser.DuplicatePolicy) (map[string]string, error) {
+		vars := make(map[string]string)
+		positions := make(map[string]parser.Position)
+		p := parser.New(parser.WithDuplicates(policy))
+
+		err1 := p.Parse(bytes.NewReader([]byte(first)), vars, positions, nil)
+		err2 := p.Parse(bytes.NewReader([]byte(second)), vars, positions, nil)
+
+		return vars, errors.Join(err1, err2)
+	}
+
+	vars, err := parse(parser.DuplicateLastWins)
+	if err != nil {
+		t.Fatal("expected no error:", err)
+	}
+
+	if !reflect.DeepEqual(vars, map[string]string{"A": "2", "B": "2", "C": "2"}) {
+		t.Fatalf("incorrect vars: %q", vars)
+	}
+
+	vars, err = parse(parser.DuplicateFirstWins)
+	if err != nil {
+		t.Fatal("expected no error:", err)
+	}
+
+	if !reflect.DeepEqual(vars, map[string]string{"A": "1", "B": "1", "C": "2"}) {
+		t.Fatalf("incorrect vars: %q", vars)
+	}
+
+	_, err = parse(parser.DuplicateError)
+
+	expected := `line 3, column 1: duplicate key "A" (first defined at line 1, column 1)
+line 1, column 1: duplicate key "B" (first defined at line 2, column 1)`
+	if err == nil || err.Error() != expected {
+		t.Fatal("incorrect error:", err)
+	}
+}
+
 type errReader struct{}
 
 func (e *errReader) Read(_ []byte) (n int, err error) {
@@ -151,7 +393,7 @@ func (e *errReader) Read(_ []byte) (n int, err error) {
 
 func TestParseWithReaderError(t *testing.T) {
 	vars := make(map[string]string)
-	err := parser.New().Parse(&errReader{}, vars)
+	err := parser.New().Parse(&errReader{}, vars, nil, nil)
 
 	if len(vars) > 0 {
 		t.Error("expected empty map")
@@ -176,7 +418,7 @@ func Benchmark_Parse(b *testing.B) {
 	b.ResetTimer()
 
 	for range b.N {
-		err := benchParser.Parse(reader, vars)
+		err := benchParser.Parse(reader, vars, nil, nil)
 		if err != nil {
 			b.Fatal(err)
 		}
@@ -193,12 +435,12 @@ func FuzzParse(f *testing.F) {
 
 	f.Fuzz(func(t *testing.T, input string) {
 		varsFirst := make(map[string]string)
-		if err := fuzzParser.Parse(bytes.NewReader([]byte(input)), varsFirst); err != nil {
+		if err := fuzzParser.Parse(bytes.NewReader([]byte(input)), varsFirst, nil, nil); err != nil {
 			t.Error(err)
 		}
 
 		varsSecond := make(map[string]string)
-		if err := fuzzParser.Parse(bytes.NewReader([]byte(input)), varsSecond); err != nil {
+		if err := fuzzParser.Parse(bytes.NewReader([]byte(input)), varsSecond, nil, nil); err != nil {
 			t.Error(err)
 		}
 
@@ -6,19 +6,49 @@ import (
 )
 
 type stream struct {
-	reader  *bufio.Reader
-	current rune
+	reader   *bufio.Reader
+	current  rune
+	previous rune
+
+	// Line and column of the current rune, starting at 1.
+	line   int
+	column int
 }
 
 func (s *stream) advance() (err error) {
+	if s.line == 0 || s.current == '\n' {
+		s.line++
+		s.column = 0
+	}
+
+	s.previous = s.current
 	s.current, _, err = s.reader.ReadRune()
+	s.column++
+
 	return
 }
 
+// peek returns the next n bytes without advancing, or fewer if the input ends.
+func (s *stream) peek(n int) []byte {
+	next, _ := s.reader.Peek(n)
+	return next
+}
+
+// skip advances over the next n bytes, which must not contain line ends.
+func (s *stream) skip(n int) {
+	skipped, _ := s.reader.Discard(n)
+	s.column += skipped
+}
+
 func (s *stream) isAtCommentBegin() bool {
 	return s.current == '#'
 }
 
+// isAfterSpace reports whether the current rune is at line start or after whitespace.
+func (s *stream) isAfterSpace() bool {
+	return s.previous == 0 || unicode.IsSpace(s.previous)
+}
+
 func (s *stream) isAtLineEnd() bool {
 	return s.current == '\n' || s.current == '\r'
 }
@@ -27,6 +57,18 @@ func (s *stream) isAtEqualSign() bool {
 	return s.current == '='
 }
 
+func (s *stream) isAtDoubleQuote() bool {
+	return s.current == '"'
+}
+
+func (s *stream) isAtSingleQuote() bool {
+	return s.current == '\''
+}
+
+func (s *stream) isAtEscape() bool {
+	return s.current == '\\'
+}
+
 func (s *stream) isAtSpace() bool {
 	return unicode.IsSpace(s.current)
 }
@@ -1,5 +1,7 @@
 package parser
 
+import "unicode"
+
 type tokenKind byte
 
 const (
@@ -11,6 +13,15 @@ const (
 
 	// Parser is in the comment scope: `name=value # COMMENT`.
 	commentToken
+
+	// Parser is in the double-quoted variable value scope, escapes are processed: `name="VALUE\n"`.
+	doubleQuotedValueToken
+
+	// Parser is in the single-quoted variable value scope, everything is literal: `name='VALUE'`.
+	singleQuotedValueToken
+
+	// Parser is after the closing quote of a value, until the end of line: `name="value" IGNORED`.
+	quotedValueEndToken
 )
 
 type token []rune
@@ -22,3 +33,14 @@ func (t *token) append(r rune) {
 func (t *token) reset() {
 	*t = nil
 }
+
+// isBlank reports whether the token has only spaces.
+func (t *token) isBlank() bool {
+	for _, r := range *t {
+		if !unicode.IsSpace(r) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,344 @@
+package reader
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultUnit      = "ns"
+	defaultSeparator = ","
+
+	defaultKeyValueSeparator = ":"
+)
+
+var (
+	durationType          = reflect.TypeOf(time.Duration(0))
+	decoderType           = reflect.TypeOf((*decoder)(nil)).Elem()
+	textUnmarshalerType   = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
+
+// decoder is implemented by types which decode themselves from a string value.
+type decoder interface {
+	Decode(value string) error
+}
+
+// decode converts the value to the type of v and sets it.
+func (r *Reader) decode(field *reflect.StructField, v reflect.Value, value string) error {
+	if parse, ok := r.parsers[v.Type()]; ok {
+		return setParsedValue(v, value, parse)
+	}
+
+	// Pointers are allocated only when a value is present.
+	if v.Kind() == reflect.Pointer {
+		ptr := reflect.New(v.Type().Elem())
+		if err := r.decode(field, ptr.Elem(), value); err != nil {
+			return err
+		}
+
+		v.Set(ptr)
+
+		return nil
+	}
+
+	if implementsDecoder(v.Type()) {
+		return unmarshal(v, value)
+	}
+
+	if isDuration(field, v.Type()) {
+		return setDurationValue(field, v, value)
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		parsed, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		v.SetInt(parsed)
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		parsed, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		v.SetUint(parsed)
+	case reflect.Float32:
+		parsed, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		v.SetFloat(parsed)
+	case reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		v.SetFloat(parsed)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		v.SetBool(parsed)
+	case reflect.Slice:
+		return r.setSliceValue(field, v, value)
+	case reflect.Array:
+		return r.setArrayValue(field, v, value)
+	case reflect.Map:
+		return r.setMapValue(field, v, value)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+// setParsedValue converts the value with a registered parse function.
+func setParsedValue(v reflect.Value, value string, parse ParseFunc) error {
+	parsed, err := parse(value)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if parsed == nil {
+		v.SetZero()
+		return nil
+	}
+
+	result := reflect.ValueOf(parsed)
+	if !result.Type().AssignableTo(v.Type()) {
+		return fmt.Errorf("parser for type %s returned type %s", v.Type(), result.Type())
+	}
+
+	v.Set(result)
+
+	return nil
+}
+
+// unmarshal decodes the value using the method implemented by the type with either value or pointer receiver,
+// in order of precedence: Decode, UnmarshalText, UnmarshalBinary.
+func unmarshal(v reflect.Value, value string) error {
+	if v.Kind() == reflect.Map && v.IsNil() {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+
+	target := v
+	if v.CanAddr() {
+		target = v.Addr()
+	}
+
+	switch u := target.Interface().(type) {
+	case decoder:
+		return u.Decode(value) //nolint:wrapcheck
+	case encoding.TextUnmarshaler:
+		return u.UnmarshalText([]byte(value)) //nolint:wrapcheck
+	case encoding.BinaryUnmarshaler:
+		return u.UnmarshalBinary([]byte(value)) //nolint:wrapcheck
+	default:
+		return fmt.Errorf("type %s cannot be decoded", v.Type())
+	}
+}
+
+// setSliceValue splits the value into elements and decodes each of them.
+// A byte slice is set to the raw value, unless the separator tag is given.
+func (r *Reader) setSliceValue(field *reflect.StructField, v reflect.Value, value string) error {
+	if _, hasSeparator := field.Tag.Lookup(separatorTag); !hasSeparator && v.Type().Elem().Kind() == reflect.Uint8 {
+		v.SetBytes([]byte(value))
+		return nil
+	}
+
+	elements := split(field, value)
+	slice := reflect.MakeSlice(v.Type(), len(elements), len(elements))
+
+	for i, element := range elements {
+		if err := r.decode(field, slice.Index(i), element.value); err != nil {
+			return &elementError{index: strconv.Itoa(element.position), err: err}
+		}
+	}
+
+	v.Set(slice)
+
+	return nil
+}
+
+// setArrayValue splits the value into elements and decodes each of them.
+// Elements missing from the value are left with their zero value.
+func (r *Reader) setArrayValue(field *reflect.StructField, v reflect.Value, value string) error {
+	elements := split(field, value)
+	if len(elements) > v.Len() {
+		return fmt.Errorf("%d elements exceed array length %d", len(elements), v.Len())
+	}
+
+	array := reflect.New(v.Type()).Elem()
+
+	for i, element := range elements {
+		if err := r.decode(field, array.Index(i), element.value); err != nil {
+			return &elementError{index: strconv.Itoa(element.position), err: err}
+		}
+	}
+
+	v.Set(array)
+
+	return nil
+}
+
+// setMapValue splits the value into key/value pairs (`a:1,b:2`) and decodes each of them.
+// Pairs are separated by the separator tag (comma by default),
+// keys and values are separated by the kvsep tag (colon by default).
+func (r *Reader) setMapValue(field *reflect.StructField, v reflect.Value, value string) error {
+	separator, hasSeparator := field.Tag.Lookup(keyValueSeparatorTag)
+	if !hasSeparator || separator == "" {
+		separator = defaultKeyValueSeparator
+	}
+
+	m := reflect.MakeMap(v.Type())
+
+	for _, element := range split(field, value) {
+		mapKey, mapValue, ok := strings.Cut(element.value, separator)
+		if !ok {
+			return &elementError{index: element.value, err: fmt.Errorf("missing key/value separator %q", separator)}
+		}
+
+		if err := r.setMapEntry(field, m, strings.TrimSpace(mapKey), strings.TrimSpace(mapValue)); err != nil {
+			return err
+		}
+	}
+
+	v.Set(m)
+
+	return nil
+}
+
+// setMapEntry decodes a key and a value and adds them to the map.
+func (r *Reader) setMapEntry(field *reflect.StructField, m reflect.Value, mapKey, mapValue string) error {
+	k := reflect.New(m.Type().Key()).Elem()
+	if err := r.decode(field, k, mapKey); err != nil {
+		return &elementError{index: mapKey, err: err}
+	}
+
+	v := reflect.New(m.Type().Elem()).Elem()
+	if err := r.decode(field, v, mapValue); err != nil {
+		return &elementError{index: mapKey, err: err}
+	}
+
+	m.SetMapIndex(k, v)
+
+	return nil
+}
+
+// splitElement is an element of a split value, with its position among all elements of the value,
+// empty ones included, so errors point to the element as written (`PORTS[2]` of `1,,x`).
+type splitElement struct {
+	value    string
+	position int
+}
+
+// split separates the value by the separator tag (comma by default).
+// Elements are trimmed and empty ones are skipped.
+func split(field *reflect.StructField, value string) []splitElement {
+	separator, hasSeparator := field.Tag.Lookup(separatorTag)
+	if !hasSeparator || separator == "" {
+		separator = defaultSeparator
+	}
+
+	parts := strings.Split(value, separator)
+	elements := make([]splitElement, 0, len(parts))
+
+	for i, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			elements = append(elements, splitElement{value: part, position: i})
+		}
+	}
+
+	return elements
+}
+
+// isDuration reports whether the type is a duration: either a time.Duration,
+// or an int64 based type (like `type Timeout time.Duration`) of a field with the unit tag set.
+func isDuration(field *reflect.StructField, typ reflect.Type) bool {
+	if typ == durationType {
+		return true
+	}
+
+	_, hasUnit := field.Tag.Lookup(unitTag)
+
+	return hasUnit && typ.Kind() == reflect.Int64
+}
+
+// setDurationValue parses Go duration syntax (`2s`, `1h30m`).
+// Numbers without unit (`2000`) are interpreted using the unit tag, nanoseconds by default.
+func setDurationValue(field *reflect.StructField, fieldValue reflect.Value, value string) error {
+	value = strings.TrimSpace(value)
+
+	if value == "" {
+		return fmt.Errorf("time: invalid duration %q", value)
+	}
+
+	if last := value[len(value)-1:]; strings.ContainsAny(last, "0123456789.") {
+		unit := field.Tag.Get(unitTag)
+		if unit == "" {
+			unit = defaultUnit
+		}
+
+		value += unit
+	}
+
+	v, err := time.ParseDuration(value)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	fieldValue.SetInt(int64(v))
+
+	return nil
+}
+
+// isDecodable reports whether the type (or the type it points to) is decoded as a whole
+// by a registered parse function or by implementing either Decode, UnmarshalText or UnmarshalBinary.
+func (r *Reader) isDecodable(typ reflect.Type) bool {
+	for {
+		if _, ok := r.parsers[typ]; ok {
+			return true
+		}
+
+		if typ.Kind() != reflect.Pointer {
+			return implementsDecoder(typ)
+		}
+
+		typ = typ.Elem()
+	}
+}
+
+// implementsDecoder reports whether the type decodes itself by implementing
+// either Decode, UnmarshalText or UnmarshalBinary.
+func implementsDecoder(typ reflect.Type) bool {
+	ptr := reflect.PointerTo(indirect(typ))
+
+	return ptr.Implements(decoderType) || ptr.Implements(textUnmarshalerType) || ptr.Implements(binaryUnmarshalerType)
+}
+
+// isSequence reports whether the type is a slice or an array.
+func isSequence(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array
+}
+
+// indirect returns the type pointed by a pointer type.
+func indirect(typ reflect.Type) reflect.Type {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	return typ
+}
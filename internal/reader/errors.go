@@ -0,0 +1,108 @@
+package reader
+
+import (
+	"fmt"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// FieldError describes a problem with a field of the struct.
+type FieldError struct {
+	// Path of the field in the struct (`Redis.Port`).
+	Path string
+
+	// Key of the value (`REDIS_PORT`), including the failing element of slices, arrays and maps (`PORTS[2]`).
+	Key string
+
+	// Value read for the field, redacted if the field is marked as secret (`secret:"true"`).
+	Value string
+
+	// Source (file name) and line where the key is defined, if known.
+	Source string
+	Line   int
+
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	var location string
+
+	switch {
+	case e.Source != "" && e.Line > 0:
+		location = fmt.Sprintf("%s:%d: ", e.Source, e.Line)
+	case e.Source != "":
+		location = e.Source + ": "
+	case e.Line > 0:
+		location = fmt.Sprintf("line %d: ", e.Line)
+	}
+
+	return fmt.Sprintf("%sfield %s (key %s): %s", location, e.Path, e.Key, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// StructError is the error returned by the Validate method of a struct.
+type StructError struct {
+	// Path of the struct (`Redis.TLS`), empty for the root struct.
+	Path string
+
+	Err error
+}
+
+func (e *StructError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("validate: %s", e.Err)
+	}
+
+	return fmt.Sprintf("validate %s: %s", e.Path, e.Err)
+}
+
+func (e *StructError) Unwrap() error {
+	return e.Err
+}
+
+// Errors holds all the problems found while reading.
+type Errors []error
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+func (e Errors) Unwrap() []error {
+	return e
+}
+
+// elementError is the error of an element of a slice, array or map.
+type elementError struct {
+	index string
+	err   error
+}
+
+func (e *elementError) Error() string {
+	return fmt.Sprintf("[%s]: %s", e.index, e.err)
+}
+
+func (e *elementError) Unwrap() error {
+	return e.err
+}
+
+// redactedError hides the message of an error which could contain a secret value.
+type redactedError struct {
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return "invalid value " + redacted
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
@@ -1,19 +1,111 @@
 package reader
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 )
 
 const (
-	tag             = "env"
+	defaultTag      = "env"
 	defaultValueTag = "default"
+	unitTag         = "unit"
+	separatorTag    = "sep"
+
+	keyValueSeparatorTag = "kvsep"
+	requiredTag          = "required"
+	requiredOption       = "required"
+	secretTag            = "secret"
 )
 
-// ValueReader is read a function that accepts a key and returns its associated value.
-type ValueReader func(*string) string
+// ErrRequired is the error of a required key which is not found.
+var ErrRequired = errors.New("required key not found")
+
+// ParseFunc converts a value to a custom type.
+type ParseFunc func(value string) (any, error)
+
+// Reader binds values from a Source to a struct.
+type Reader struct {
+	parsers      map[reflect.Type]ParseFunc
+	tag          string
+	defaultTag   string
+	keyTransform func(string) string
+	prefix       string
+
+	// Tag naming the fields in nested keys (`redis.port`), empty for flat keys (`REDIS_PORT`).
+	nestedTag string
+}
+
+// Option configures the Reader.
+type Option func(*Reader)
+
+// WithParser registers a function to convert values to the given type.
+// It takes precedence over any other conversion of the type.
+func WithParser(typ reflect.Type, parse ParseFunc) Option {
+	return func(r *Reader) {
+		r.parsers[typ] = parse
+	}
+}
+
+// WithTag sets the tag holding the key of a field (`env` by default).
+func WithTag(tag string) Option {
+	return func(r *Reader) {
+		r.tag = tag
+	}
+}
+
+// WithDefaultTag sets the tag holding the default value of a field (`default` by default).
+func WithDefaultTag(tag string) Option {
+	return func(r *Reader) {
+		r.defaultTag = tag
+	}
+}
+
+// WithKeyTransform sets the function applied to the path of a field without a tag to generate its key
+// (strings.ToUpper by default). The path is made of the names of the fields joined by underscore (`Redis_Port`).
+func WithKeyTransform(transform func(string) string) Option {
+	return func(r *Reader) {
+		r.keyTransform = transform
+	}
+}
+
+// WithPrefix sets a prefix added to all keys.
+func WithPrefix(prefix string) Option {
+	return func(r *Reader) {
+		r.prefix = prefix
+	}
+}
+
+// WithNestedKeys makes the keys of the fields their path in a hierarchical source (`redis.port`), made of the names
+// given by the tag (`json:"port"`), or of the field names, joined by dots. Fields tagged with `-` are skipped.
+// Keys are matched case-insensitively, and slices and arrays are also bound from the keys of their elements
+// (`ports[0]`, `servers[0].host`), maps of structs, slices, arrays and maps from the nested keys of their values
+// (`servers.primary.host`). The key tag, the key transform and the prefix are not used.
+func WithNestedKeys(tag string) Option {
+	return func(r *Reader) {
+		r.nestedTag = tag
+	}
+}
+
+// New creates a Reader configured with the given options.
+func New(opts ...Option) *Reader {
+	r := &Reader{
+		parsers:      make(map[reflect.Type]ParseFunc),
+		tag:          defaultTag,
+		defaultTag:   defaultValueTag,
+		keyTransform: strings.ToUpper,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
 
 // ReadToStruct takes a pointer to a struct and a ValueReader function.
 // For each property of the struct it (recursively) generates a key that represents the property.
@@ -21,130 +113,744 @@ type ValueReader func(*string) string
 //
 // Panics for types different than pointer to a struct.
 func ReadToStruct(structPtr any, readValue ValueReader) error {
-	typ := reflect.TypeOf(structPtr)
+	return New().Read(structPtr, readValue)
+}
+
+// Read binds the values of a Source to the struct, the same way ReadToStruct does,
+// then validates the struct with its Validate methods (see Validate).
+// All problems (conversion failures, required keys not found, validation failures) are collected
+// and returned as Errors of *FieldError and *StructError.
+//
+// Panics for types different than pointer to a struct.
+func (r *Reader) Read(structPtr any, source Source) error {
+	st := &state{source: source, parsing: make(map[reflect.Type]int), separator: r.keySeparator()[0]}
+
+	if r.nestedTag != "" {
+		st.folded = make(map[string]string)
+		for _, key := range source.Keys() {
+			st.folded[strings.ToLower(key)] = key
+		}
+
+		st.documents, _ = source.(DocumentSource)
+	}
+
+	r.parse(reflect.ValueOf(structPtr).Elem(), st, "", "")
+
+	var validationErrs Errors
+	if errors.As(r.Validate(structPtr), &validationErrs) {
+		st.errs = append(st.errs, validationErrs...)
+	}
+
+	if len(st.errs) > 0 {
+		return st.errs
+	}
+
+	return nil
+}
+
+// state holds the data of a single Read call.
+type state struct {
+	source Source
+	errs   Errors
+
+	// Lowercase keys of the source mapped to the keys, to match nested keys case-insensitively.
+	folded map[string]string
+
+	// Documents of the keys of the source, with nested keys, if the source holds them.
+	documents DocumentSource
+
+	// Struct types on the path of the struct being parsed, counted by their occurrences.
+	parsing map[reflect.Type]int
+
+	// Separator of the keys of map entries from their map key, and the index of the keys, built on first use.
+	separator byte
+	index     *keyIndex
+}
+
+// lookup returns the value of a key and the key as found in the source,
+// matched case-insensitively with nested keys.
+func (st *state) lookup(key string) (string, string) {
+	value := st.source.Lookup(key)
+	if value != "" || st.folded == nil {
+		return key, value
+	}
+
+	if sourceKey, ok := st.folded[strings.ToLower(key)]; ok {
+		return sourceKey, st.source.Lookup(sourceKey)
+	}
+
+	return key, value
+}
+
+// elements returns the number of elements of a slice or array found in the source
+// by the keys of its elements (`ports[0]`, `servers[1].host`), with nested keys.
+func (st *state) elements(key string) int {
+	return st.keyIndex().elements[strings.ToLower(key)]
+}
+
+// prefixed returns the keys of the source beginning with a prefix which ends with the key separator
+// (`HEADERS_`, `headers.`), sorted. Nested keys are matched case-insensitively.
+func (st *state) prefixed(prefix string) []string {
+	if st.folded != nil {
+		prefix = strings.ToLower(prefix)
+	}
+
+	return st.keyIndex().prefixed[prefix]
+}
+
+// keyIndex returns the index of the keys of the source, built on first use.
+func (st *state) keyIndex() *keyIndex {
+	if st.index == nil {
+		st.index = newKeyIndex(st.source.Keys(), st.separator, st.folded != nil)
+	}
+
+	return st.index
+}
+
+// keyIndex locates the keys of a source by their prefixes, so fields do not scan all keys.
+type keyIndex struct {
+	// Sorted keys by each of their prefixes ending with the separator (`REDIS_` of `REDIS_TLS_CERT`).
+	prefixed map[string][]string
 
-	return parse(typ, reflect.ValueOf(structPtr), readValue, "")
+	// Number of elements of slices and arrays by their key (`ports` of `ports[2]` and `ports[0]`).
+	elements map[string]int
 }
 
-func parse(typ reflect.Type, val reflect.Value, readValue ValueReader, path string) error {
-	if typ.Kind() == reflect.Ptr {
-		typ = typ.Elem()
+// newKeyIndex indexes the keys by their prefixes ending with the separator, and by the keys of slices
+// and arrays followed by the indices of their elements. Prefixes are lowercase if the keys are folded.
+func newKeyIndex(keys []string, separator byte, fold bool) *keyIndex {
+	index := &keyIndex{prefixed: make(map[string][]string), elements: make(map[string]int)}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		folded := key
+		if fold {
+			folded = strings.ToLower(key)
+		}
+
+		for i := range len(folded) {
+			switch folded[i] {
+			case separator:
+				index.prefixed[folded[:i+1]] = append(index.prefixed[folded[:i+1]], key)
+			case '[':
+				element, _, _ := strings.Cut(folded[i+1:], "]")
+				if n, err := strconv.Atoi(element); err == nil && n >= index.elements[folded[:i]] {
+					index.elements[folded[:i]] = n + 1
+				}
+			}
+		}
+	}
+
+	return index
+}
+
+// addError records a problem with a field, locating its key in the source.
+// Values of fields marked as secret are redacted. Errors without value (required key not found, empty value)
+// are kept, as they cannot reveal the secret.
+func (st *state) addError(field *reflect.StructField, fieldPath, key, value string, err error) {
+	fieldErr := &FieldError{Path: fieldPath, Key: key, Value: value, Err: err}
+
+	// Point to the failing element of a slice, array or map.
+	var elemErr *elementError
+	if errors.As(err, &elemErr) {
+		fieldErr.Key += "[" + elemErr.index + "]"
+		fieldErr.Err = elemErr.err
 	}
 
+	if secret, parseErr := strconv.ParseBool(field.Tag.Get(secretTag)); parseErr == nil && secret && value != "" {
+		fieldErr.Value = redacted
+		fieldErr.Err = &redactedError{err: fieldErr.Err}
+	}
+
+	fieldErr.Source, fieldErr.Line = st.source.Locate(key)
+
+	st.errs = append(st.errs, fieldErr)
+}
+
+// parse binds values to all fields of a struct and reports if any value was found for them
+// (default values are not considered).
+func (r *Reader) parse(val reflect.Value, st *state, path, fieldPath string) bool {
+	typ := val.Type()
+	found := false
+
+	st.parsing[typ]++
+	defer func() { st.parsing[typ]-- }()
+
 	for i := range typ.NumField() {
 		field := typ.Field(i)
+		if !field.IsExported() || r.isSkipped(&field) {
+			continue
+		}
+
+		fieldKeyPath := r.fieldKeyPath(&field, path)
+		currentFieldPath := fieldPath + field.Name
+
+		// Parse struct and pointer to struct recursively.
+		switch {
+		case r.decodesDocument(st, field.Type):
+			if key, document := st.documents.Document(r.generateKey(&field, fieldKeyPath)); document != "" {
+				r.setDocument(st, &field, val.Field(i), document, key, currentFieldPath)
+				found = true
+
+				continue
+			}
+
+			// Without document, the default value is decoded as the other values.
+		case r.isDecodable(field.Type):
+			// Types decoding themselves are read from their own value.
+		case field.Type.Kind() == reflect.Struct:
+			found = r.parse(val.Field(i), st, fieldKeyPath, currentFieldPath+".") || found
+
+			continue
+		case field.Type.Kind() == reflect.Pointer && st.parsing[field.Type.Elem()] > 0:
+			// Pointers back to a struct on the path (`Next *Node`) would be allocated endlessly.
+			continue
+		case field.Type.Kind() == reflect.Pointer && field.Type.Elem().Kind() == reflect.Struct:
+			structFound := r.parseStructPointer(val.Field(i), st, fieldKeyPath, currentFieldPath+".")
+
+			if !structFound && val.Field(i).IsNil() && r.isRequired(&field) {
+				key := r.generateKey(&field, fieldKeyPath)
+				st.addError(&field, currentFieldPath, key, "", ErrRequired)
+			}
+
+			found = found || structFound
+
+			continue
+		case field.Type.Kind() == reflect.Map:
+			siblings := r.fieldKeys(st, typ, path)
+			found = r.parseMap(&field, val.Field(i), st, fieldKeyPath, currentFieldPath, siblings) || found
+
+			continue
+		case isSequence(field.Type) && st.elements(r.generateKey(&field, fieldKeyPath)) > 0:
+			r.parseElements(&field, val.Field(i), st, r.generateKey(&field, fieldKeyPath), currentFieldPath)
+			found = true
 
-		if val.Kind() == reflect.Ptr {
-			val = val.Elem()
+			continue
+		}
+
+		key, value := r.getValue(&field, st, fieldKeyPath)
+		if value != "" {
+			found = true
+		} else if value = r.getDefaultValue(&field); value == "" {
+			r.checkMissing(st, &field, key, currentFieldPath)
+
+			continue
+		}
+
+		r.setFieldValue(st, &field, val.Field(i), value, key, currentFieldPath)
+	}
+
+	return found
+}
+
+// parseStructPointer binds values to a struct pointed by a field.
+// A nil pointer is allocated only if at least one value is found for the struct.
+func (r *Reader) parseStructPointer(val reflect.Value, st *state, path, fieldPath string) bool {
+	if !val.IsNil() {
+		return r.parse(val.Elem(), st, path, fieldPath)
+	}
+
+	ptr := reflect.New(val.Type().Elem())
+	errs := len(st.errs)
+
+	if r.parse(ptr.Elem(), st, path, fieldPath) {
+		val.Set(ptr)
+		return true
+	}
+
+	// Required fields of a struct which is not set at all are not enforced.
+	st.errs = st.errs[:errs]
+
+	return false
+}
+
+// parseMap binds to a map field both the value of its key (`a:1,b:2`)
+// and the values of all keys prefixed by its key (`KEY_A=1`, `KEY_B=2`), the latter taking precedence.
+func (r *Reader) parseMap(
+	field *reflect.StructField,
+	val reflect.Value,
+	st *state,
+	path, fieldPath string,
+	siblings []string,
+) bool {
+	key, value := r.getValue(field, st, path)
+	found := value != ""
+	errs := len(st.errs)
+
+	if found {
+		if err := r.decode(field, val, value); err != nil {
+			st.addError(field, fieldPath, key, value, err)
 		}
+	}
 
-		path += field.Name + "_"
+	mapKey := r.generateKey(field, path)
+	found = r.setMapEntries(field, val, st, mapKey, fieldPath, fieldPath, siblings) || found
 
-		// Parse struct recursively.
-		if field.Type.Kind() == reflect.Struct {
-			if err := parse(field.Type, val.Field(i), readValue, path); err != nil {
-				return err
+	switch {
+	case found:
+		// Validate the map only once all its entries are set.
+		if len(st.errs) == errs {
+			if err := r.validate(field, val); err != nil {
+				st.addError(field, fieldPath, mapKey, value, err)
 			}
+		}
+	case r.getDefaultValue(field) != "":
+		r.setFieldValue(st, field, val, r.getDefaultValue(field), key, fieldPath)
+	default:
+		r.checkMissing(st, field, mapKey, fieldPath)
+	}
+
+	return found
+}
 
-			path = ""
+// setMapEntries binds to a map the values of all keys prefixed by its key (`KEY_A=1`, `KEY_B=2`) and reports
+// if any is found. With nested keys, struct, slice, array and map values are bound from their own nested keys
+// (`key.a.host`, `key.a[0]`), as the elements of slices and arrays. Keys of the other fields of the struct
+// (siblings) are not entries of the map (`DB_TIMEOUT` of the field next to `DB`).
+func (r *Reader) setMapEntries(
+	field *reflect.StructField,
+	val reflect.Value,
+	st *state,
+	key, fieldPath, valuePath string,
+	siblings []string,
+) bool {
+	prefix := key + r.keySeparator()
+	bound := make(map[string]bool)
+	found := false
+
+	var claimed []string
+	for _, sibling := range siblings {
+		if _, ok := r.cutKeyPrefix(sibling, prefix); ok && len(sibling) > len(prefix) {
+			claimed = append(claimed, sibling)
+		}
+	}
+
+	for _, sourceKey := range st.prefixed(prefix) {
+		entryKey, ok := r.cutKeyPrefix(sourceKey, prefix)
+		if !ok || entryKey == "" || r.isClaimed(sourceKey, claimed) {
+			continue
 		}
 
-		value := getValue(&field, readValue, path)
+		if mapKey, ok := r.nestedMapKey(st, entryKey, val.Type().Elem()); ok {
+			if !bound[mapKey] {
+				bound[mapKey] = true
+				found = r.setNestedMapEntry(field, val, st, prefix+mapKey, mapKey, fieldPath, valuePath) || found
+			}
+
+			continue
+		}
+
+		value := st.source.Lookup(sourceKey)
 		if value == "" {
 			continue
 		}
 
-		if err := setFieldValue(&field, val.Field(i), value); err != nil {
-			return err
+		if val.IsNil() {
+			val.Set(reflect.MakeMap(val.Type()))
 		}
 
-		// After value is set, start again with a new property.
-		path = ""
+		if err := r.setMapEntry(field, val, entryKey, value); err != nil {
+			var elemErr *elementError
+			if errors.As(err, &elemErr) {
+				err = elemErr.err
+			}
+
+			st.addError(field, fieldPath, sourceKey, value, err)
+		}
+
+		found = true
 	}
 
-	return nil
+	return found
 }
 
-func getValue(field *reflect.StructField, readValue ValueReader, path string) (value string) {
-	// Generate key and read value.
-	key := generateKey(field, path)
-	value = readValue(&key)
+// isClaimed reports whether a key is bound by one of the keys of other fields,
+// as their own key (`DB_TIMEOUT`) or as a key of their entries (`DB_HOSTS_MAIN` of `DB_HOSTS`).
+func (r *Reader) isClaimed(sourceKey string, keys []string) bool {
+	for _, key := range keys {
+		rest, ok := r.cutKeyPrefix(sourceKey, key)
+		if ok && (rest == "" || rest[0] == r.keySeparator()[0]) {
+			return true
+		}
+	}
 
-	// If empty, read value from field name.
+	return false
+}
+
+// fieldKeys returns the keys of the fields of a struct, with the keys of the fields of its embedded structs,
+// which share its path.
+func (r *Reader) fieldKeys(st *state, typ reflect.Type, path string) []string {
+	var keys []string
+
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		if !field.IsExported() || r.isSkipped(&field) {
+			continue
+		}
+
+		fieldKeyPath := r.fieldKeyPath(&field, path)
+
+		embedded := field.Type
+		if embedded.Kind() == reflect.Pointer {
+			embedded = embedded.Elem()
+		}
+
+		if fieldKeyPath == path && embedded.Kind() == reflect.Struct && !r.isDecodable(field.Type) {
+			// Embedded pointers back to a struct on the path are skipped, as when parsed.
+			if st.parsing[embedded] == 0 {
+				st.parsing[embedded]++
+				keys = append(keys, r.fieldKeys(st, embedded, path)...)
+				st.parsing[embedded]--
+			}
+
+			continue
+		}
+
+		keys = append(keys, r.generateKey(&field, fieldKeyPath))
+	}
+
+	return keys
+}
+
+// setNestedMapEntry binds the value of a map key from its nested keys (`key.a.host`)
+// and adds it to the map if any value is found.
+func (r *Reader) setNestedMapEntry(
+	field *reflect.StructField,
+	m reflect.Value,
+	st *state,
+	key, mapKey, fieldPath, valuePath string,
+) bool {
+	v := reflect.New(m.Type().Elem()).Elem()
+	if !r.parseValue(field, v, st, key, fieldPath, valuePath+"["+mapKey+"]") {
+		return false
+	}
+
+	k := reflect.New(m.Type().Key()).Elem()
+	if err := r.decode(field, k, mapKey); err != nil {
+		st.addError(field, fieldPath, key, "", err)
+		return true
+	}
+
+	if m.IsNil() {
+		m.Set(reflect.MakeMap(m.Type()))
+	}
+
+	m.SetMapIndex(k, v)
+
+	return true
+}
+
+// parseElements binds to a slice or array field the values of the keys of its elements (`ports[0]`),
+// or of the nested keys of its struct, slice, array and map elements (`servers[0].host`), with nested keys.
+func (r *Reader) parseElements(field *reflect.StructField, val reflect.Value, st *state, key, fieldPath string) {
+	errs := len(st.errs)
+
+	r.setElements(field, val, st, key, fieldPath, fieldPath)
+
+	// Validate the slice or array only once all its elements are set.
+	if len(st.errs) == errs {
+		if err := r.validate(field, val); err != nil {
+			st.addError(field, fieldPath, key, "", err)
+		}
+	}
+}
+
+// setElements binds to a slice or array the values of the keys of its elements (`ports[0]`, `servers[0].host`).
+func (r *Reader) setElements(
+	field *reflect.StructField,
+	val reflect.Value,
+	st *state,
+	key, fieldPath, valuePath string,
+) {
+	length := st.elements(key)
+
+	var elements reflect.Value
+
+	switch {
+	case val.Kind() == reflect.Slice:
+		elements = reflect.MakeSlice(val.Type(), length, length)
+	case length > val.Len():
+		st.addError(field, fieldPath, key, "", fmt.Errorf("%d elements exceed array length %d", length, val.Len()))
+		return
+	default:
+		elements = reflect.New(val.Type()).Elem()
+	}
+
+	for i := range length {
+		index := "[" + strconv.Itoa(i) + "]"
+		r.parseValue(field, elements.Index(i), st, key+index, fieldPath, valuePath+index)
+	}
+
+	val.Set(elements)
+}
+
+// parseValue binds to an element of a slice, array or map field either the value of its key (`ports[0]`),
+// or the values of its nested keys (`servers[0].host`, `matrix[0][1]`, `routes.api.hosts[0]`), and reports
+// if any is found. Problems of struct fields are located by the path of the value (`Servers[0].Host`),
+// the other ones by the path of the field.
+func (r *Reader) parseValue(
+	field *reflect.StructField,
+	val reflect.Value,
+	st *state,
+	key, fieldPath, valuePath string,
+) bool {
+	switch typ := val.Type(); {
+	case r.decodesDocument(st, typ):
+		sourceKey, document := st.documents.Document(key)
+		if document == "" {
+			return false
+		}
+
+		if err := st.documents.Decode(document, val); err != nil {
+			st.addError(field, fieldPath, sourceKey, document, err)
+		}
+
+		return true
+	case r.isDecodable(typ):
+		// Types decoding themselves are read from their own value.
+	case typ.Kind() == reflect.Struct:
+		return r.parse(val, st, key+".", valuePath+".")
+	case typ.Kind() == reflect.Pointer && typ.Elem().Kind() == reflect.Struct:
+		return r.parseStructPointer(val, st, key+".", valuePath+".")
+	case typ.Kind() == reflect.Map:
+		if r.setMapEntries(field, val, st, key, fieldPath, valuePath, nil) {
+			return true
+		}
+	case isSequence(typ) && st.elements(key) > 0:
+		r.setElements(field, val, st, key, fieldPath, valuePath)
+		return true
+	}
+
+	sourceKey, value := st.lookup(key)
 	if value == "" {
-		value = readValue(&field.Name)
+		return false
+	}
+
+	if err := r.decode(field, val, value); err != nil {
+		st.addError(field, fieldPath, sourceKey, value, err)
+	}
+
+	return true
+}
+
+func (r *Reader) getValue(field *reflect.StructField, st *state, path string) (key, value string) {
+	// Generate key and read value.
+	key = r.generateKey(field, path)
+	if r.nestedTag != "" {
+		return st.lookup(key)
 	}
 
-	// If empty, get default.
+	value = st.source.Lookup(key)
+
+	// If empty, read value from field name.
 	if value == "" {
-		value = getDefaultValue(field)
+		if value = st.source.Lookup(r.prefix + field.Name); value != "" {
+			key = r.prefix + field.Name
+		}
 	}
 
 	return
 }
 
-func generateKey(field *reflect.StructField, path string) (key string) {
+func (r *Reader) generateKey(field *reflect.StructField, path string) (key string) {
+	// Nested keys are the path itself.
+	if r.nestedTag != "" {
+		return strings.TrimSuffix(path, ".")
+	}
+
 	// Get configured key.
-	key = field.Tag.Get(tag)
+	key, _ = r.parseTag(field)
 
 	// If empty, generate from path (path is property name or struct name + property name).
 	if key == "" {
-		key = strings.ToUpper(strings.TrimSuffix(path, "_"))
+		key = r.keyTransform(strings.TrimSuffix(path, "_"))
 	}
 
-	return
+	return r.prefix + key
 }
 
-func getDefaultValue(field *reflect.StructField) string {
-	return field.Tag.Get(defaultValueTag)
+// fieldKeyPath returns the path of a field used to generate its key: the names of the fields joined by underscore
+// (`Redis_Port_`), or by dots with nested keys (`redis.port.`). Embedded structs do not add their name to the path,
+// unless named by the nested tag.
+func (r *Reader) fieldKeyPath(field *reflect.StructField, path string) string {
+	if r.nestedTag == "" {
+		if field.Anonymous {
+			return path
+		}
+
+		return path + field.Name + "_"
+	}
+
+	name, _, _ := strings.Cut(field.Tag.Get(r.nestedTag), ",")
+
+	switch {
+	case name != "":
+		return path + name + "."
+	case field.Anonymous:
+		return path
+	default:
+		return path + field.Name + "."
+	}
 }
 
-func setFieldValue(field *reflect.StructField, fieldValue reflect.Value, value string) error {
-	switch field.Type.Kind() {
-	case reflect.String:
-		if fieldValue.CanSet() {
-			fieldValue.SetString(value)
-		}
-	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
-		v, err := strconv.ParseInt(value, 10, 0)
-		if err != nil {
-			return fmt.Errorf("field %s (%w)", field.Name, err)
-		}
+// isSkipped reports whether the field is excluded from nested keys (`json:"-"`).
+func (r *Reader) isSkipped(field *reflect.StructField) bool {
+	return r.nestedTag != "" && field.Tag.Get(r.nestedTag) == "-"
+}
 
-		fieldValue.SetInt(v)
-	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
-		v, err := strconv.ParseUint(value, 10, 0)
-		if err != nil {
-			return fmt.Errorf("field %s (%w)", field.Name, err)
-		}
+// keySeparator returns the separator of the keys of map entries from their map key:
+// underscore (`HEADERS_ACCEPT`), or dot with nested keys (`headers.accept`).
+func (r *Reader) keySeparator() string {
+	if r.nestedTag != "" {
+		return "."
+	}
 
-		fieldValue.SetUint(v)
-	case reflect.Float32:
-		v, err := strconv.ParseFloat(value, 32)
-		if err != nil {
-			return fmt.Errorf("field %s (%w)", field.Name, err)
-		}
+	return "_"
+}
+
+// hasNestedKeys reports whether values of the type are bound from nested keys (`a.host`, `a[0]`) when they are
+// elements of a map, like structs, slices, arrays and maps which do not decode themselves.
+func (r *Reader) hasNestedKeys(typ reflect.Type) bool {
+	if r.isDecodable(typ) {
+		return false
+	}
+
+	switch typ.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		return true
+	case reflect.Pointer:
+		return typ.Elem().Kind() == reflect.Struct
+	default:
+		return false
+	}
+}
 
-		fieldValue.SetFloat(v)
-	case reflect.Float64:
-		v, err := strconv.ParseFloat(value, 64)
-		if err != nil {
-			return fmt.Errorf("field %s (%w)", field.Name, err)
+// decodesDocument reports whether values of the type are decoded from the documents of the source,
+// unless a registered parse function or a Decode method takes precedence.
+func (r *Reader) decodesDocument(st *state, typ reflect.Type) bool {
+	if st.documents == nil || reflect.PointerTo(indirect(typ)).Implements(decoderType) {
+		return false
+	}
+
+	for t := typ; ; t = t.Elem() {
+		if _, ok := r.parsers[t]; ok {
+			return false
 		}
 
-		fieldValue.SetFloat(v)
-	case reflect.Bool:
-		v, err := strconv.ParseBool(value)
-		if err != nil {
-			return fmt.Errorf("field %s (%w)", field.Name, err)
+		if t.Kind() != reflect.Pointer {
+			return st.documents.Decodes(typ)
 		}
+	}
+}
+
+// nestedMapKey returns the map key of an entry bound from its nested keys (`a` of `a.host` or `a[0]`), or from its
+// document (`a`), with nested keys. It reports false for entries holding their own value (`a`).
+// Map keys of slices and arrays end only at their first element (`a.b` of `a.b[0]`).
+func (r *Reader) nestedMapKey(st *state, entryKey string, typ reflect.Type) (string, bool) {
+	if r.nestedTag == "" {
+		return "", false
+	}
+
+	document := r.decodesDocument(st, typ)
+
+	separators := ".["
+	if isSequence(typ) && !document {
+		separators = "["
+	}
+
+	i := strings.IndexAny(entryKey, separators)
 
-		fieldValue.SetBool(v)
-	case reflect.Slice:
-		fieldValue.SetBytes([]byte(value))
+	switch {
+	case i > 0 && (document || r.hasNestedKeys(typ)):
+		return entryKey[:i], true
+	case i == -1 && document:
+		return entryKey, true
 	default:
-		// Nothing to do.
+		return "", false
 	}
+}
 
-	return nil
+// cutKeyPrefix removes the prefix from a key of the source, matched case-insensitively with nested keys.
+func (r *Reader) cutKeyPrefix(key, prefix string) (string, bool) {
+	if r.nestedTag == "" {
+		return strings.CutPrefix(key, prefix)
+	}
+
+	if len(key) < len(prefix) || !strings.EqualFold(key[:len(prefix)], prefix) {
+		return key, false
+	}
+
+	return key[len(prefix):], true
+}
+
+// parseTag splits the key tag into the key and its options (`env:"KEY,required"`).
+func (r *Reader) parseTag(field *reflect.StructField) (key string, options []string) {
+	key, rest, _ := strings.Cut(field.Tag.Get(r.tag), ",")
+	if rest != "" {
+		options = strings.Split(rest, ",")
+	}
+
+	return key, options
+}
+
+// isRequired reports whether the field is marked as required
+// either with the required tag (`required:"true"`) or with the key tag option (`env:"KEY,required"`).
+func (r *Reader) isRequired(field *reflect.StructField) bool {
+	if required, err := strconv.ParseBool(field.Tag.Get(requiredTag)); err == nil && required {
+		return true
+	}
+
+	_, options := r.parseTag(field)
+
+	return slices.Contains(options, requiredOption)
+}
+
+func (r *Reader) getDefaultValue(field *reflect.StructField) string {
+	return field.Tag.Get(r.defaultTag)
+}
+
+// checkMissing reports a field without value (nor default value) if it is required or must not be empty.
+func (r *Reader) checkMissing(st *state, field *reflect.StructField, key, fieldPath string) {
+	if r.isRequired(field) {
+		st.addError(field, fieldPath, key, "", ErrRequired)
+		return
+	}
+
+	if nonEmpty, err := strconv.ParseBool(field.Tag.Get(nonEmptyTag)); err == nil && nonEmpty {
+		st.addError(field, fieldPath, key, "", errEmpty)
+	}
+}
+
+// setDocument decodes the document of a key into a field, then validates it.
+func (r *Reader) setDocument(
+	st *state,
+	field *reflect.StructField,
+	fieldValue reflect.Value,
+	document, key, fieldPath string,
+) {
+	if err := st.documents.Decode(document, fieldValue); err != nil {
+		st.addError(field, fieldPath, key, document, err)
+		return
+	}
+
+	if err := r.validate(field, fieldValue); err != nil {
+		st.addError(field, fieldPath, key, document, err)
+	}
+}
+
+func (r *Reader) setFieldValue(
+	st *state,
+	field *reflect.StructField,
+	fieldValue reflect.Value,
+	value, key, fieldPath string,
+) {
+	if err := r.decode(field, fieldValue, value); err != nil {
+		st.addError(field, fieldPath, key, value, err)
+		return
+	}
+
+	if err := r.validate(field, fieldValue); err != nil {
+		st.addError(field, fieldPath, key, value, err)
+	}
 }
@@ -1,7 +1,16 @@
 package reader_test
 
 import (
+	"errors"
+	"log/slog"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/andreiavrammsd/config/internal/reader"
 )
@@ -72,6 +81,14 @@ func assertEqual[T comparable](t *testing.T, actual, expected T) {
 	}
 }
 
+func assertDeepEqual(t *testing.T, actual, expected any) {
+	t.Helper()
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("%v != %v", actual, expected)
+	}
+}
+
 func TestReadToStruct(t *testing.T) {
 	configStruct := config{}
 
@@ -121,7 +138,7 @@ func TestReadToStructWithIntParseError(t *testing.T) {
 		t.Fatal("error expected")
 	}
 
-	if err.Error() != "field Value (strconv.ParseInt: parsing \"invalid int value\": invalid syntax)" {
+	if err.Error() != "field Value (key VALUE): strconv.ParseInt: parsing \"invalid int value\": invalid syntax" {
 		t.Fatal("incorrect error message:", err)
 	}
 }
@@ -141,7 +158,30 @@ func TestReadToStructWithUintParseError(t *testing.T) {
 		t.Fatal("error expected")
 	}
 
-	if err.Error() != "field Value (strconv.ParseUint: parsing \"invalid uint value\": invalid syntax)" {
+	if err.Error() != "field Value (key VALUE): strconv.ParseUint: parsing \"invalid uint value\": invalid syntax" {
+		t.Fatal("incorrect error message:", err)
+	}
+}
+
+func TestReadWithIntegerOverflow(t *testing.T) {
+	configStruct := struct {
+		I8    int8
+		U16   uint16
+		I32s  []int32
+		U8s   [2]uint8
+		Bound int8 `max:"300"`
+	}{}
+
+	source := reader.Map{"I8": "300", "U16": "70000", "I32S": "1,3000000000", "U8S": "1,-1", "BOUND": "1"}
+
+	err := reader.New().Read(&configStruct, source)
+
+	expected := `field I8 (key I8): strconv.ParseInt: parsing "300": value out of range
+field U16 (key U16): strconv.ParseUint: parsing "70000": value out of range
+field I32s (key I32S[1]): strconv.ParseInt: parsing "3000000000": value out of range
+field U8s (key U8S[1]): strconv.ParseUint: parsing "-1": invalid syntax
+field Bound (key BOUND): invalid max tag "300": strconv.ParseInt: parsing "300": value out of range`
+	if err == nil || err.Error() != expected {
 		t.Fatal("incorrect error message:", err)
 	}
 }
@@ -161,7 +201,7 @@ func TestReadToStructWithFloat32ParseError(t *testing.T) {
 		t.Fatal("error expected")
 	}
 
-	if err.Error() != "field Value (strconv.ParseFloat: parsing \"invalid float32 value\": invalid syntax)" {
+	if err.Error() != "field Value (key VALUE): strconv.ParseFloat: parsing \"invalid float32 value\": invalid syntax" {
 		t.Fatal("incorrect error message:", err)
 	}
 }
@@ -181,7 +221,7 @@ func TestReadToStructWithFloat64ParseError(t *testing.T) {
 		t.Fatal("error expected")
 	}
 
-	if err.Error() != "field Value (strconv.ParseFloat: parsing \"invalid float64 value\": invalid syntax)" {
+	if err.Error() != "field Value (key VALUE): strconv.ParseFloat: parsing \"invalid float64 value\": invalid syntax" {
 		t.Fatal("incorrect error message:", err)
 	}
 }
@@ -201,7 +241,7 @@ func TestReadToStructWithBoolParseError(t *testing.T) {
 		t.Fatal("error expected")
 	}
 
-	if err.Error() != "field Value (strconv.ParseBool: parsing \"invalid bool value\": invalid syntax)" {
+	if err.Error() != "field Value (key VALUE): strconv.ParseBool: parsing \"invalid bool value\": invalid syntax" {
 		t.Fatal("incorrect error message:", err)
 	}
 }
@@ -225,7 +265,1249 @@ func TestReadToStructWithInnerStructParseError(t *testing.T) {
 		t.Fatal("error expected")
 	}
 
-	if err.Error() != "field Integer (strconv.ParseInt: parsing \"invalid struct integer value\": invalid syntax)" {
+	expected := "field Struct.Integer (key STRUCT_INTEGER): " +
+		"strconv.ParseInt: parsing \"invalid struct integer value\": invalid syntax"
+	if err.Error() != expected {
+		t.Fatal("incorrect error message:", err)
+	}
+}
+
+type timeout time.Duration
+
+func TestReadToStructWithDuration(t *testing.T) {
+	configStruct := struct {
+		Timeout     time.Duration
+		Interval    time.Duration
+		Nanoseconds time.Duration
+		Millis      time.Duration `unit:"ms"`
+		Fraction    time.Duration `unit:"s"`
+		Named       timeout       `unit:"s"`
+		Default     time.Duration `default:"1m"`
+	}{}
+
+	readValue := func(s *string) string {
+		vars := make(map[string]string)
+		vars["TIMEOUT"] = "2s"
+		vars["INTERVAL"] = "1h30m"
+		vars["NANOSECONDS"] = "2000000000"
+		vars["MILLIS"] = "1500"
+		vars["FRACTION"] = "0.5"
+		vars["NAMED"] = "3"
+		return vars[*s]
+	}
+
+	err := reader.ReadToStruct(&configStruct, readValue)
+	if err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	assertEqual(t, configStruct.Timeout, 2*time.Second)
+	assertEqual(t, configStruct.Interval, time.Hour+30*time.Minute)
+	assertEqual(t, configStruct.Nanoseconds, 2*time.Second)
+	assertEqual(t, configStruct.Millis, 1500*time.Millisecond)
+	assertEqual(t, configStruct.Fraction, 500*time.Millisecond)
+	assertEqual(t, configStruct.Named, timeout(3*time.Second))
+	assertEqual(t, configStruct.Default, time.Minute)
+}
+
+func TestReadToStructWithDurationParseError(t *testing.T) {
+	configStruct := struct {
+		Server struct {
+			Timeout time.Duration
+		}
+	}{}
+
+	readValue := func(s *string) string {
+		vars := make(map[string]string)
+		vars["SERVER_TIMEOUT"] = "2 seconds"
+		return vars[*s]
+	}
+
+	err := reader.ReadToStruct(&configStruct, readValue)
+
+	if err == nil {
+		t.Fatal("error expected")
+	}
+
+	expected := "field Server.Timeout (key SERVER_TIMEOUT): " +
+		"time: unknown unit \" seconds\" in duration \"2 seconds\""
+	if err.Error() != expected {
+		t.Fatal("incorrect error message:", err)
+	}
+}
+
+func TestReadToStructWithPointers(t *testing.T) {
+	type inner struct {
+		Name string
+		Port *int
+	}
+
+	existing := &inner{Name: "existing"}
+
+	configStruct := struct {
+		Integer  *int
+		Zero     *int
+		Unset    *int
+		String   *string
+		Bool     *bool
+		Duration *time.Duration
+		Default  *string `default:"default value"`
+		Struct   *inner
+		Missing  *inner
+		Existing *inner
+		Outer    *struct {
+			Inner *inner
+		}
+	}{Existing: existing}
+
+	readValue := func(s *string) string {
+		vars := make(map[string]string)
+		vars["INTEGER"] = "10"
+		vars["ZERO"] = "0"
+		vars["STRING"] = "text"
+		vars["BOOL"] = "true"
+		vars["DURATION"] = "1s"
+		vars["STRUCT_NAME"] = "struct name"
+		vars["STRUCT_PORT"] = "8080"
+		vars["EXISTING_PORT"] = "9090"
+		vars["OUTER_INNER_NAME"] = "inner name"
+		return vars[*s]
+	}
+
+	err := reader.ReadToStruct(&configStruct, readValue)
+	if err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	assertEqual(t, *configStruct.Integer, 10)
+	assertEqual(t, *configStruct.Zero, 0)
+	assertEqual(t, configStruct.Unset, nil)
+	assertEqual(t, *configStruct.String, "text")
+	assertEqual(t, *configStruct.Bool, true)
+	assertEqual(t, *configStruct.Duration, time.Second)
+	assertEqual(t, *configStruct.Default, "default value")
+
+	assertEqual(t, configStruct.Struct.Name, "struct name")
+	assertEqual(t, *configStruct.Struct.Port, 8080)
+
+	assertEqual(t, configStruct.Missing, nil)
+
+	assertEqual(t, configStruct.Existing, existing)
+	assertEqual(t, configStruct.Existing.Name, "existing")
+	assertEqual(t, *configStruct.Existing.Port, 9090)
+
+	assertEqual(t, configStruct.Outer.Inner.Name, "inner name")
+	assertEqual(t, configStruct.Outer.Inner.Port, nil)
+}
+
+func TestReadToStructWithEmbeddedStruct(t *testing.T) {
+	type Embedded struct {
+		Host string
+	}
+
+	configStruct := struct {
+		Embedded
+		Named Embedded
+	}{}
+
+	readValue := func(s *string) string {
+		vars := make(map[string]string)
+		vars["HOST"] = "embedded"
+		vars["NAMED_HOST"] = "named"
+		return vars[*s]
+	}
+
+	err := reader.ReadToStruct(&configStruct, readValue)
+	if err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	assertEqual(t, configStruct.Host, "embedded")
+	assertEqual(t, configStruct.Named.Host, "named")
+}
+
+func TestReadToStructWithStructPointerParseError(t *testing.T) {
+	configStruct := struct {
+		Struct *struct {
+			Integer *int
+		}
+	}{}
+
+	readValue := func(s *string) string {
+		vars := make(map[string]string)
+		vars["STRUCT_INTEGER"] = "invalid struct integer value"
+		return vars[*s]
+	}
+
+	err := reader.ReadToStruct(&configStruct, readValue)
+
+	if err == nil {
+		t.Fatal("error expected")
+	}
+
+	expected := "field Struct.Integer (key STRUCT_INTEGER): " +
+		"strconv.ParseInt: parsing \"invalid struct integer value\": invalid syntax"
+	if err.Error() != expected {
+		t.Fatal("incorrect error message:", err)
+	}
+}
+
+type node struct {
+	Name string
+	Next *node
+}
+
+func TestReadWithSelfReferencingPointer(t *testing.T) {
+	configStruct := struct {
+		Head *node
+		Tail node
+	}{}
+
+	source := reader.Map{"HEAD_NAME": "head", "HEAD_NEXT_NAME": "next", "TAIL_NAME": "tail"}
+
+	if err := reader.New().Read(&configStruct, source); err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	assertDeepEqual(t, configStruct.Head, &node{Name: "head"})
+	assertDeepEqual(t, configStruct.Tail, node{Name: "tail"})
+}
+
+func TestReadToStructWithSlicesAndArrays(t *testing.T) {
+	configStruct := struct {
+		Strings   []string
+		Ports     []int
+		Unsigned  []uint16
+		Floats    []float64 `sep:";"`
+		Bools     []bool    `sep:" "`
+		Durations []time.Duration
+		Pointers  []*int
+		Numbers   []uint8 `sep:","`
+		Bytes     []byte
+		Array     [3]int
+		Short     [3]string
+		Empty     []string
+		Default   []string `default:"a,b"`
+	}{}
+
+	readValue := func(s *string) string {
+		vars := make(map[string]string)
+		vars["STRINGS"] = " a , b,,c, "
+		vars["PORTS"] = "80,443"
+		vars["UNSIGNED"] = "1,2"
+		vars["FLOATS"] = "1.5;-2.5"
+		vars["BOOLS"] = "true false  true"
+		vars["DURATIONS"] = "1s,2m,3"
+		vars["POINTERS"] = "1,2"
+		vars["NUMBERS"] = "1,2,3"
+		vars["BYTES"] = "a,b"
+		vars["ARRAY"] = "1,2,3"
+		vars["SHORT"] = "x"
+		vars["EMPTY"] = ","
+		return vars[*s]
+	}
+
+	err := reader.ReadToStruct(&configStruct, readValue)
+	if err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	assertDeepEqual(t, configStruct.Strings, []string{"a", "b", "c"})
+	assertDeepEqual(t, configStruct.Ports, []int{80, 443})
+	assertDeepEqual(t, configStruct.Unsigned, []uint16{1, 2})
+	assertDeepEqual(t, configStruct.Floats, []float64{1.5, -2.5})
+	assertDeepEqual(t, configStruct.Bools, []bool{true, false, true})
+	assertDeepEqual(t, configStruct.Durations, []time.Duration{time.Second, 2 * time.Minute, 3})
+	assertEqual(t, *configStruct.Pointers[0], 1)
+	assertEqual(t, *configStruct.Pointers[1], 2)
+	assertDeepEqual(t, configStruct.Numbers, []uint8{1, 2, 3})
+	assertDeepEqual(t, configStruct.Bytes, []byte("a,b"))
+	assertEqual(t, configStruct.Array, [3]int{1, 2, 3})
+	assertEqual(t, configStruct.Short, [3]string{"x", "", ""})
+	assertDeepEqual(t, configStruct.Empty, []string{})
+	assertDeepEqual(t, configStruct.Default, []string{"a", "b"})
+}
+
+func TestReadToStructWithSliceElementParseError(t *testing.T) {
+	configStruct := struct{ Ports []int }{}
+
+	readValue := func(s *string) string {
+		vars := make(map[string]string)
+		vars["PORTS"] = "80,443,http"
+		return vars[*s]
+	}
+
+	err := reader.ReadToStruct(&configStruct, readValue)
+
+	if err == nil {
+		t.Fatal("error expected")
+	}
+
+	if err.Error() != "field Ports (key PORTS[2]): strconv.ParseInt: parsing \"http\": invalid syntax" {
+		t.Fatal("incorrect error message:", err)
+	}
+
+	// Elements are numbered by their position in the value, empty ones included.
+	err = reader.New().Read(&configStruct, reader.Map{"PORTS": "1,,x"})
+
+	if err == nil || err.Error() != "field Ports (key PORTS[2]): strconv.ParseInt: parsing \"x\": invalid syntax" {
+		t.Fatal("incorrect error message:", err)
+	}
+}
+
+func TestReadToStructWithArrayLengthError(t *testing.T) {
+	configStruct := struct{ Array [2]int }{}
+
+	readValue := func(s *string) string {
+		vars := make(map[string]string)
+		vars["ARRAY"] = "1,2,3"
+		return vars[*s]
+	}
+
+	err := reader.ReadToStruct(&configStruct, readValue)
+
+	if err == nil {
+		t.Fatal("error expected")
+	}
+
+	if err.Error() != "field Array (key ARRAY): 3 elements exceed array length 2" {
+		t.Fatal("incorrect error message:", err)
+	}
+}
+
+func TestReadWithMaps(t *testing.T) {
+	configStruct := struct {
+		Flags    map[string]bool
+		Limits   map[string]int `sep:";" kvsep:"="`
+		Ports    map[int]string
+		Headers  map[string]string
+		Timeouts map[string]time.Duration `unit:"ms"`
+		Merged   map[string]int
+		Default  map[string]string `default:"a:b"`
+		Missing  map[string]string
+	}{}
+
+	source := reader.Map{
+		"FLAGS":                    "a:true, b : false",
+		"LIMITS":                   "x=1;y=2",
+		"PORTS":                    "80:http,443:https",
+		"HEADERS_X_REQUEST_ID":     "id",
+		"HEADERS_Content-Type":     "text/plain",
+		"HEADERS_":                 "ignored",
+		"HEADERS_EMPTY":            "",
+		"NOT_HEADERS_X_REQUEST_ID": "ignored",
+		"HEADERSX_REQUEST_ID":      "ignored",
+		"TIMEOUTS_READ":            "100",
+		"TIMEOUTS_WRITE":           "1s",
+		"MERGED":                   "a:1,b:2",
+		"MERGED_b":                 "3",
+	}
+
+	err := reader.New().Read(&configStruct, source)
+	if err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	assertDeepEqual(t, configStruct.Flags, map[string]bool{"a": true, "b": false})
+	assertDeepEqual(t, configStruct.Limits, map[string]int{"x": 1, "y": 2})
+	assertDeepEqual(t, configStruct.Ports, map[int]string{80: "http", 443: "https"})
+	assertDeepEqual(t, configStruct.Headers, map[string]string{"X_REQUEST_ID": "id", "Content-Type": "text/plain"})
+	assertDeepEqual(
+		t,
+		configStruct.Timeouts,
+		map[string]time.Duration{"READ": 100 * time.Millisecond, "WRITE": time.Second},
+	)
+	assertDeepEqual(t, configStruct.Merged, map[string]int{"a": 1, "b": 3})
+	assertDeepEqual(t, configStruct.Default, map[string]string{"a": "b"})
+	assertDeepEqual(t, configStruct.Missing, map[string]string(nil))
+}
+
+type DBOptions struct {
+	SSL bool `env:"DB_SSL"`
+}
+
+func TestReadWithMapsNextToPrefixedFields(t *testing.T) {
+	configStruct := struct {
+		DB map[string]string
+		DBOptions
+		DBTimeout int               `env:"DB_TIMEOUT"`
+		DBHosts   map[string]string `env:"DB_HOSTS"`
+		Limits    map[string]int
+		LimitsMax int `env:"LIMITS_MAX"`
+	}{}
+
+	source := reader.Map{
+		"DB_NAME":       "app",
+		"DB_SSL":        "true",
+		"DB_TIMEOUT":    "5",
+		"DB_HOSTS_MAIN": "primary",
+		"LIMITS_MIN":    "1",
+		"LIMITS_MAX":    "abc",
+	}
+
+	err := reader.New().Read(&configStruct, source)
+
+	expected := "field LimitsMax (key LIMITS_MAX): strconv.ParseInt: parsing \"abc\": invalid syntax"
+	if err == nil || err.Error() != expected {
+		t.Fatal("incorrect error:", err)
+	}
+
+	assertDeepEqual(t, configStruct.DB, map[string]string{"NAME": "app"})
+	assertEqual(t, configStruct.SSL, true)
+	assertEqual(t, configStruct.DBTimeout, 5)
+	assertDeepEqual(t, configStruct.DBHosts, map[string]string{"MAIN": "primary"})
+	assertDeepEqual(t, configStruct.Limits, map[string]int{"MIN": 1})
+}
+
+func TestReadWithMapParseErrors(t *testing.T) {
+	tests := map[string]struct {
+		source reader.Map
+		err    string
+	}{
+		"missing separator": {
+			source: reader.Map{"VALUES": "a:1,b"},
+			err:    "field Values (key VALUES[b]): missing key/value separator \":\"",
+		},
+		"invalid value": {
+			source: reader.Map{"VALUES": "a:x"},
+			err:    "field Values (key VALUES[a]): strconv.ParseInt: parsing \"x\": invalid syntax",
+		},
+		"invalid prefixed value": {
+			source: reader.Map{"VALUES_A": "x"},
+			err:    "field Values (key VALUES_A): strconv.ParseInt: parsing \"x\": invalid syntax",
+		},
+		"invalid key": {
+			source: reader.Map{"KEYS": "x:1"},
+			err:    "field Keys (key KEYS[x]): strconv.ParseInt: parsing \"x\": invalid syntax",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			configStruct := struct {
+				Values map[string]int
+				Keys   map[int]int
+			}{}
+
+			err := reader.New().Read(&configStruct, test.source)
+
+			if err == nil {
+				t.Fatal("error expected")
+			}
+
+			if err.Error() != test.err {
+				t.Fatal("incorrect error message:", err)
+			}
+		})
+	}
+}
+
+type color int
+
+func (c *color) Decode(value string) error {
+	switch value {
+	case "red":
+		*c = 1
+	case "green":
+		*c = 2
+	default:
+		return errors.New("unknown color")
+	}
+
+	return nil
+}
+
+type words map[string]bool
+
+func (w words) Decode(value string) error {
+	for _, word := range strings.Fields(value) {
+		w[word] = true
+	}
+
+	return nil
+}
+
+type upper struct {
+	Value string
+}
+
+func (u *upper) UnmarshalText(text []byte) error {
+	u.Value = strings.ToUpper(string(text))
+	return nil
+}
+
+func TestReadToStructWithDecoders(t *testing.T) {
+	configStruct := struct {
+		IP       net.IP
+		URL      url.URL
+		URLPtr   *url.URL
+		Regexp   *regexp.Regexp
+		Level    slog.Level
+		Time     time.Time
+		Color    color
+		Colors   []color
+		ColorPtr *color
+		Words    words
+		Upper    upper
+		Default  color `default:"green"`
+	}{}
+
+	readValue := func(s *string) string {
+		vars := make(map[string]string)
+		vars["IP"] = "127.0.0.1"
+		vars["URL"] = "https://example.com/path?q=1"
+		vars["URLPTR"] = "http://localhost:8080"
+		vars["REGEXP"] = "^a+$"
+		vars["LEVEL"] = "warn"
+		vars["TIME"] = "2024-01-02T03:04:05Z"
+		vars["COLOR"] = "red"
+		vars["COLORS"] = "green,red"
+		vars["COLORPTR"] = "green"
+		vars["WORDS"] = "a b"
+		vars["UPPER"] = "text"
+		vars["UPPER_VALUE"] = "not used"
+		return vars[*s]
+	}
+
+	err := reader.ReadToStruct(&configStruct, readValue)
+	if err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	assertEqual(t, configStruct.IP.String(), "127.0.0.1")
+	assertEqual(t, configStruct.URL.Host, "example.com")
+	assertEqual(t, configStruct.URL.RawQuery, "q=1")
+	assertEqual(t, configStruct.URLPtr.Port(), "8080")
+	assertEqual(t, configStruct.Regexp.MatchString("aaa"), true)
+	assertEqual(t, configStruct.Level, slog.LevelWarn)
+	assertEqual(t, configStruct.Time, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	assertEqual(t, configStruct.Color, 1)
+	assertDeepEqual(t, configStruct.Colors, []color{2, 1})
+	assertEqual(t, *configStruct.ColorPtr, 2)
+	assertDeepEqual(t, configStruct.Words, words{"a": true, "b": true})
+	assertEqual(t, configStruct.Upper.Value, "TEXT")
+	assertEqual(t, configStruct.Default, 2)
+}
+
+func TestReadToStructWithDecoderError(t *testing.T) {
+	configStruct := struct{ Color color }{}
+
+	readValue := func(s *string) string {
+		vars := make(map[string]string)
+		vars["COLOR"] = "blue"
+		return vars[*s]
+	}
+
+	err := reader.ReadToStruct(&configStruct, readValue)
+
+	if err == nil {
+		t.Fatal("error expected")
+	}
+
+	if err.Error() != "field Color (key COLOR): unknown color" {
+		t.Fatal("incorrect error message:", err)
+	}
+}
+
+func TestReadWithParser(t *testing.T) {
+	configStruct := struct {
+		Color   color
+		Colors  []color
+		Wrapped struct{ Value string }
+	}{}
+
+	r := reader.New(
+		reader.WithParser(reflect.TypeOf(color(0)), func(value string) (any, error) {
+			return color(len(value)), nil
+		}),
+		reader.WithParser(reflect.TypeOf(struct{ Value string }{}), func(value string) (any, error) {
+			return struct{ Value string }{Value: value}, nil
+		}),
+	)
+
+	err := r.Read(&configStruct, reader.Map{"COLOR": "blue", "COLORS": "a,bb", "WRAPPED": "text"})
+	if err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	assertEqual(t, configStruct.Color, 4)
+	assertDeepEqual(t, configStruct.Colors, []color{1, 2})
+	assertEqual(t, configStruct.Wrapped.Value, "text")
+}
+
+func TestReadWithParserReturningIncorrectType(t *testing.T) {
+	configStruct := struct{ Value int }{}
+
+	r := reader.New(reader.WithParser(reflect.TypeOf(0), func(value string) (any, error) {
+		return value, nil
+	}))
+
+	err := r.Read(&configStruct, reader.Map{"VALUE": "1"})
+
+	if err == nil {
+		t.Fatal("error expected")
+	}
+
+	if err.Error() != "field Value (key VALUE): parser for type int returned type string" {
+		t.Fatal("incorrect error message:", err)
+	}
+}
+
+func TestReadWithRequired(t *testing.T) {
+	configStruct := struct {
+		URL      string            `env:"DB_URL,required"`
+		Host     string            `required:"true"`
+		Port     int               `required:"true"    default:"80"`
+		Optional string            `required:"false"`
+		Set      string            `required:"true"`
+		Headers  map[string]string `required:"true"`
+		Database struct {
+			Name string `required:"true"`
+		}
+		TLS *struct {
+			Cert string `required:"true"`
+		}
+		Auth *struct {
+			User string `required:"true"`
+			Pass string `required:"true"`
+		}
+		Cache *struct{ Size int } `env:",required"`
+	}{}
+
+	err := reader.New().Read(&configStruct, reader.Map{"SET": "value", "AUTH_USER": "user"})
+
+	if !errors.Is(err, reader.ErrRequired) {
+		t.Fatal("incorrect error:", err)
+	}
+
+	expected := `field URL (key DB_URL): required key not found
+field Host (key HOST): required key not found
+field Headers (key HEADERS): required key not found
+field Database.Name (key DATABASE_NAME): required key not found
+field Auth.Pass (key AUTH_PASS): required key not found
+field Cache (key CACHE): required key not found`
+	if err.Error() != expected {
+		t.Fatal("incorrect error message:", err)
+	}
+
+	assertEqual(t, configStruct.Set, "value")
+	assertEqual(t, configStruct.Port, 80)
+}
+
+type locatedSource struct {
+	reader.Map
+}
+
+func (locatedSource) Locate(key string) (string, int) {
+	return ".env", len(key)
+}
+
+func TestReadWithAggregatedErrors(t *testing.T) {
+	configStruct := struct {
+		Port     int
+		Password int `secret:"true"`
+		Hosts    []int
+		Name     string `required:"true"`
+		Valid    int
+		Token    string `required:"true" secret:"true"`
+	}{}
+
+	source := locatedSource{reader.Map{"PORT": "http", "PASSWORD": "s3cr3t", "HOSTS": "1,x", "VALID": "1"}}
+
+	err := reader.New().Read(&configStruct, source)
+
+	var errs reader.Errors
+	if !errors.As(err, &errs) {
+		t.Fatal("incorrect error type:", err)
+	}
+
+	if len(errs) != 5 {
+		t.Fatalf("expected 5 errors, got %d", len(errs))
+	}
+
+	var fieldErr *reader.FieldError
+	if !errors.As(errs[0], &fieldErr) {
+		t.Fatal("incorrect error type:", errs[0])
+	}
+
+	assertEqual(t, fieldErr.Path, "Port")
+	assertEqual(t, fieldErr.Key, "PORT")
+	assertEqual(t, fieldErr.Value, "http")
+	assertEqual(t, fieldErr.Source, ".env")
+	assertEqual(t, fieldErr.Line, 4)
+
+	var numErr *strconv.NumError
+	if !errors.As(err, &numErr) {
+		t.Fatal("expected wrapped strconv error")
+	}
+
+	if !errors.Is(err, reader.ErrRequired) {
+		t.Fatal("expected wrapped required error")
+	}
+
+	expected := `.env:4: field Port (key PORT): strconv.ParseInt: parsing "http": invalid syntax
+.env:8: field Password (key PASSWORD): invalid value [REDACTED]
+.env:5: field Hosts (key HOSTS[1]): strconv.ParseInt: parsing "x": invalid syntax
+.env:4: field Name (key NAME): required key not found
+.env:5: field Token (key TOKEN): required key not found`
+	if err.Error() != expected {
+		t.Fatal("incorrect error message:", err)
+	}
+
+	if !errors.As(errs[1], &fieldErr) {
+		t.Fatal("incorrect error type:", errs[1])
+	}
+
+	assertEqual(t, fieldErr.Value, "[REDACTED]")
+	assertEqual(t, configStruct.Valid, 1)
+}
+
+func TestReadWithValidation(t *testing.T) {
+	configStruct := struct {
+		Port     int           `min:"1" max:"65535"`
+		Ratio    float64       `min:"0" max:"1"`
+		Level    string        `oneof:"debug|info|warn"`
+		Name     string        `regexp:"^[a-z]+$" len:"4"`
+		Timeout  time.Duration `min:"1s" max:"1m"`
+		Hosts    []string      `min:"1" max:"3" oneof:"a|b|c"`
+		Codes    []int         `nonempty:"true"`
+		Labels   map[string]string
+		LogLevel slog.Level `min:"INFO"`
+		Retries  *uint      `max:"5"`
+		Optional int        `min:"10"`
+	}{}
+
+	source := reader.Map{
+		"PORT":     "8080",
+		"RATIO":    "0.5",
+		"LEVEL":    "info",
+		"NAME":     "john",
+		"TIMEOUT":  "30s",
+		"HOSTS":    "a,c",
+		"CODES":    "1,2",
+		"LOGLEVEL": "WARN",
+		"RETRIES":  "3",
+	}
+
+	if err := reader.New().Read(&configStruct, source); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	assertEqual(t, configStruct.Port, 8080)
+	assertEqual(t, configStruct.Timeout, 30*time.Second)
+	assertEqual(t, *configStruct.Retries, 3)
+}
+
+func TestReadWithValidationErrors(t *testing.T) {
+	configStruct := struct {
+		Port    int            `min:"1" max:"65535"`
+		Ratio   float64        `min:"0"`
+		Level   string         `oneof:"debug|info|warn"`
+		Name    string         `regexp:"^[a-z]+$"`
+		Code    string         `len:"3"`
+		Timeout time.Duration  `max:"1m"`
+		Hosts   []string       `oneof:"a|b|c"`
+		Ports   []int          `max:"2"`
+		Codes   []int          `sep:";" nonempty:"true"`
+		Labels  map[string]int `max:"1"`
+		Secret  string         `nonempty:"true"`
+		Bool    bool           `min:"1"`
+	}{}
+
+	source := reader.Map{
+		"PORT":     "70000",
+		"RATIO":    "-0.5",
+		"LEVEL":    "trace",
+		"NAME":     "John",
+		"CODE":     "ünü",
+		"TIMEOUT":  "2m",
+		"HOSTS":    "a,d",
+		"PORTS":    "1,2,3",
+		"CODES":    ";",
+		"LABELS_A": "1",
+		"LABELS_B": "2",
+		"BOOL":     "true",
+	}
+
+	err := reader.New().Read(&configStruct, source)
+
+	if !errors.Is(err, reader.ErrValidation) {
+		t.Fatal("expected validation error:", err)
+	}
+
+	expected := `field Port (key PORT): validation failed: must be at most 65535
+field Ratio (key RATIO): validation failed: must be at least 0
+field Level (key LEVEL): validation failed: must be one of debug|info|warn
+field Name (key NAME): validation failed: must match ^[a-z]+$
+field Timeout (key TIMEOUT): validation failed: must be at most 1m
+field Hosts (key HOSTS[1]): validation failed: must be one of a|b|c
+field Ports (key PORTS): validation failed: length must be at most 2
+field Codes (key CODES): validation failed: must not be empty
+field Labels (key LABELS): validation failed: length must be at most 1
+field Secret (key SECRET): validation failed: must not be empty
+field Bool (key BOOL): min tag is not supported for type bool`
+	if err.Error() != expected {
 		t.Fatal("incorrect error message:", err)
 	}
 }
+
+type poolConfig struct {
+	MinConns int
+	MaxConns int
+}
+
+func (p poolConfig) Validate() error {
+	if p.MinConns > p.MaxConns {
+		return errors.New("min conns greater than max conns")
+	}
+
+	return nil
+}
+
+type tlsConfig struct {
+	Cert string
+	Key  string
+}
+
+func (t *tlsConfig) Validate() error {
+	if (t.Cert == "") != (t.Key == "") {
+		return errors.New("cert and key must be set together")
+	}
+
+	return nil
+}
+
+type serverConfig struct {
+	poolConfig
+	Pool  poolConfig
+	TLS   *tlsConfig
+	Name  string
+	calls *[]string
+}
+
+func (s *serverConfig) Validate() error {
+	*s.calls = append(*s.calls, "server")
+
+	if s.Name == "" {
+		return errors.New("name is required")
+	}
+
+	return nil
+}
+
+func TestReadWithValidateMethods(t *testing.T) {
+	var calls []string
+
+	configStruct := serverConfig{calls: &calls}
+	source := reader.Map{"POOL_MINCONNS": "5", "POOL_MAXCONNS": "1", "TLS_CERT": "cert.pem", "MINCONNS": "9"}
+
+	err := reader.New().Read(&configStruct, source)
+
+	var structErr *reader.StructError
+	if !errors.As(err, &structErr) {
+		t.Fatal("incorrect error type:", err)
+	}
+
+	assertEqual(t, structErr.Path, "Pool")
+
+	expected := `validate Pool: min conns greater than max conns
+validate TLS: cert and key must be set together
+validate: name is required`
+	if err.Error() != expected {
+		t.Fatal("incorrect error message:", err)
+	}
+
+	// The unexported embedded struct is neither populated nor validated.
+	assertDeepEqual(t, calls, []string{"server"})
+
+	calls = nil
+	configStruct = serverConfig{calls: &calls}
+	source = reader.Map{"NAME": "api"}
+
+	if err := reader.New().Read(&configStruct, source); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+type Base struct {
+	ID    string
+	calls *[]string
+}
+
+func (b Base) Validate() error {
+	*b.calls = append(*b.calls, "base")
+
+	if b.ID == "" {
+		return errors.New("id is required")
+	}
+
+	return nil
+}
+
+// ownValidateConfig declares its own Validate method, which does not shadow the one of the embedded struct.
+type ownValidateConfig struct {
+	Base
+	Name string
+}
+
+func (c *ownValidateConfig) Validate() error {
+	*c.calls = append(*c.calls, "own")
+
+	return nil
+}
+
+// promotedValidateConfig has the Validate method of the embedded struct promoted, called only once.
+type promotedValidateConfig struct {
+	Base
+	Name string
+}
+
+func TestReadWithEmbeddedValidateMethods(t *testing.T) {
+	var calls []string
+
+	own := ownValidateConfig{Base: Base{calls: &calls}}
+	err := reader.New().Read(&own, reader.Map{"NAME": "api"})
+
+	if err == nil || err.Error() != "validate Base: id is required" {
+		t.Fatal("incorrect error:", err)
+	}
+
+	assertDeepEqual(t, calls, []string{"base", "own"})
+
+	calls = nil
+	promoted := promotedValidateConfig{Base: Base{calls: &calls}}
+	err = reader.New().Read(&promoted, reader.Map{"NAME": "api"})
+
+	if err == nil || err.Error() != "validate: id is required" {
+		t.Fatal("incorrect error:", err)
+	}
+
+	assertDeepEqual(t, calls, []string{"base"})
+}
+
+func TestReadWithValidateMethodsInCollections(t *testing.T) {
+	configStruct := struct {
+		Servers  []tlsConfig
+		Backups  []*tlsConfig
+		Pools    [2]poolConfig
+		Routes   map[string]tlsConfig
+		Matrix   [][]poolConfig
+		Deadline []time.Time
+	}{}
+
+	source := reader.Map{
+		"servers[0].cert":       "a.pem",
+		"servers[0].key":        "a.key",
+		"servers[1].cert":       "b.pem",
+		"backups[0].key":        "c.key",
+		"pools[1].minconns":     "2",
+		"routes.api.cert":       "d.pem",
+		"routes.web.cert":       "e.pem",
+		"routes.web.key":        "e.key",
+		"matrix[0][0].minconns": "1",
+	}
+
+	err := reader.New(reader.WithNestedKeys("json")).Read(&configStruct, source)
+
+	expected := `validate Servers[1]: cert and key must be set together
+validate Backups[0]: cert and key must be set together
+validate Pools[1]: min conns greater than max conns
+validate Routes[api]: cert and key must be set together
+validate Matrix[0][0]: min conns greater than max conns`
+	if err == nil || err.Error() != expected {
+		t.Fatal("incorrect error:", err)
+	}
+}
+
+func TestReadWithNestedKeys(t *testing.T) {
+	type server struct {
+		Host string
+		Port int `default:"80"`
+	}
+
+	type Embedded struct {
+		Region string
+	}
+
+	configStruct := struct {
+		Embedded
+		Name    string `json:"service_name"`
+		Ignored string `json:"-"`
+		Redis   struct {
+			Port    int           `json:"port,omitempty"`
+			Timeout time.Duration `unit:"ms"`
+		}
+		Hosts    []string
+		Ports    [3]int
+		Tags     []string
+		Servers  []server
+		Backups  []*server
+		Labels   map[string]string
+		Required string `required:"true"`
+	}{}
+
+	source := reader.Map{
+		"region":            "eu",
+		"SERVICE_NAME":      "api",
+		"Ignored":           "ignored",
+		"redis.port":        "6379",
+		"redis.timeout":     "100",
+		"hosts[0]":          "a",
+		"hosts[1]":          "b,c",
+		"ports[0]":          "1",
+		"ports[1]":          "2",
+		"tags":              "x,y",
+		"servers[0].host":   "one",
+		"servers[1].host":   "two",
+		"servers[1].port":   "8080",
+		"backups[0].host":   "backup",
+		"labels.Team":       "core",
+		"LABELS.env":        "prod",
+		"labelsx.ignored":   "ignored",
+		"REQUIRED":          "yes",
+		"required.ignored":  "ignored",
+		"servers[x].ignore": "ignored",
+	}
+
+	if err := reader.New(reader.WithNestedKeys("json")).Read(&configStruct, source); err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	assertEqual(t, configStruct.Region, "eu")
+	assertEqual(t, configStruct.Name, "api")
+	assertEqual(t, configStruct.Ignored, "")
+	assertEqual(t, configStruct.Redis.Port, 6379)
+	assertEqual(t, configStruct.Redis.Timeout, 100*time.Millisecond)
+	assertDeepEqual(t, configStruct.Hosts, []string{"a", "b,c"})
+	assertDeepEqual(t, configStruct.Ports, [3]int{1, 2, 0})
+	assertDeepEqual(t, configStruct.Tags, []string{"x", "y"})
+	assertDeepEqual(t, configStruct.Servers, []server{{"one", 80}, {"two", 8080}})
+	assertDeepEqual(t, configStruct.Backups, []*server{{"backup", 80}})
+	assertDeepEqual(t, configStruct.Labels, map[string]string{"Team": "core", "env": "prod"})
+	assertEqual(t, configStruct.Required, "yes")
+}
+
+func TestReadWithNestedCollections(t *testing.T) {
+	type point struct {
+		X int
+		Y int `default:"1"`
+	}
+
+	configStruct := struct {
+		M     map[string]point
+		MP    map[string]*point
+		MM    map[string][]int
+		MMap  map[string]map[string]int
+		AA    [][]string
+		AM    []map[string]string
+		Plain map[string][]string
+	}{}
+
+	source := reader.Map{
+		"m.a.x":        "1",
+		"m.B.X":        "2",
+		"mp.a.y":       "3",
+		"mm.k[0]":      "1",
+		"mm.k[1]":      "2",
+		"mmap.a.b":     "1",
+		"mmap.a.c":     "2",
+		"mmap.d":       "e:3",
+		"aa[0][0]":     "a",
+		"aa[1][0]":     "b",
+		"aa[1][1]":     "c",
+		"am[0].team":   "core",
+		"am[1].env":    "prod",
+		"plain.a":      "x,y",
+		"plain.b[0]":   "z",
+		"plainx.value": "ignored",
+	}
+
+	if err := reader.New(reader.WithNestedKeys("json")).Read(&configStruct, source); err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	assertDeepEqual(t, configStruct.M, map[string]point{"a": {1, 1}, "B": {2, 1}})
+	assertDeepEqual(t, configStruct.MP, map[string]*point{"a": {0, 3}})
+	assertDeepEqual(t, configStruct.MM, map[string][]int{"k": {1, 2}})
+	assertDeepEqual(t, configStruct.MMap, map[string]map[string]int{"a": {"b": 1, "c": 2}, "d": {"e": 3}})
+	assertDeepEqual(t, configStruct.AA, [][]string{{"a"}, {"b", "c"}})
+	assertDeepEqual(t, configStruct.AM, []map[string]string{{"team": "core"}, {"env": "prod"}})
+	assertDeepEqual(t, configStruct.Plain, map[string][]string{"a": {"x", "y"}, "b": {"z"}})
+}
+
+func TestReadWithNestedCollectionsErrors(t *testing.T) {
+	configStruct := struct {
+		M    map[string]struct{ X int }
+		K    map[int][]int
+		Any  map[string]any
+		List []any
+	}{}
+
+	source := reader.Map{
+		"m.a":     "1",
+		"m.b.x":   "x",
+		"k.a[0]":  "1",
+		"any.a.b": "1",
+		"list[0]": "1",
+	}
+
+	err := reader.New(reader.WithNestedKeys("json")).Read(&configStruct, source)
+
+	expected := `field M (key m.a): unsupported type struct { X int }
+field M[b].X (key m.b.x): strconv.ParseInt: parsing "x": invalid syntax
+field K (key K.a): strconv.ParseInt: parsing "a": invalid syntax
+field Any (key any.a.b): unsupported type interface {}
+field List (key list[0]): unsupported type interface {}`
+	if err == nil || err.Error() != expected {
+		t.Fatal("incorrect error:", err)
+	}
+}
+
+type phrase []string
+
+// documentSource decodes phrases from the documents of their keys, split by spaces.
+type documentSource struct {
+	reader.Map
+	documents map[string]string
+}
+
+func (s documentSource) Document(key string) (string, string) {
+	return strings.ToLower(key), s.documents[strings.ToLower(key)]
+}
+
+func (documentSource) Decodes(typ reflect.Type) bool {
+	return typ == reflect.TypeOf(phrase(nil))
+}
+
+func (documentSource) Decode(document string, v reflect.Value) error {
+	if document == "invalid" {
+		return errors.New("invalid document")
+	}
+
+	v.Set(reflect.ValueOf(phrase(strings.Fields(document))))
+
+	return nil
+}
+
+func TestReadWithDocuments(t *testing.T) {
+	type target struct {
+		Title   phrase
+		List    []phrase
+		Map     map[string]phrase
+		Default phrase `default:"a,b"`
+		Short   phrase `max:"1"`
+		Invalid phrase
+	}
+
+	source := documentSource{
+		Map: reader.Map{"title": "ignored", "list[0]": "ignored", "list[1]": "ignored", "map.x": "ignored"},
+		documents: map[string]string{
+			"title":   "hello world",
+			"list[0]": "a b",
+			"list[1]": "c",
+			"map.x":   "y z",
+			"short":   "a b",
+			"invalid": "invalid",
+		},
+	}
+
+	configStruct := target{}
+	err := reader.New(reader.WithNestedKeys("json")).Read(&configStruct, source)
+
+	expected := `field Short (key short): validation failed: length must be at most 1
+field Invalid (key invalid): invalid document`
+	if err == nil || err.Error() != expected {
+		t.Fatal("incorrect error:", err)
+	}
+
+	assertDeepEqual(t, configStruct.Title, phrase{"hello", "world"})
+	assertDeepEqual(t, configStruct.List, []phrase{{"a", "b"}, {"c"}})
+	assertDeepEqual(t, configStruct.Map, map[string]phrase{"x": {"y", "z"}})
+	assertDeepEqual(t, configStruct.Default, phrase{"a", "b"})
+
+	// Registered parse functions take precedence over documents.
+	configStruct = target{}
+	parse := func(value string) (any, error) { return phrase{value}, nil }
+
+	if err := reader.New(reader.WithNestedKeys("json"), reader.WithParser(reflect.TypeOf(phrase(nil)), parse)).
+		Read(&configStruct, source); err != nil {
+		t.Fatal("error not expected:", err)
+	}
+
+	assertDeepEqual(t, configStruct.Title, phrase{"ignored"})
+	assertDeepEqual(t, configStruct.List, []phrase{{"ignored"}, {"ignored"}})
+}
+
+func TestReadWithNestedKeysErrors(t *testing.T) {
+	configStruct := struct {
+		Ports   []int `max:"2"`
+		Pair    [2]int
+		Servers []struct {
+			Port int `required:"true"`
+		}
+		Redis struct {
+			Host string `required:"true"`
+		}
+	}{}
+
+	source := locatedSource{reader.Map{
+		"ports[0]":        "1",
+		"ports[1]":        "2",
+		"ports[2]":        "x",
+		"pair[2]":         "1",
+		"servers[0].port": "80",
+		"servers[1].host": "localhost",
+	}}
+
+	err := reader.New(reader.WithNestedKeys("json")).Read(&configStruct, source)
+
+	expected := `.env:8: field Ports (key ports[2]): strconv.ParseInt: parsing "x": invalid syntax
+.env:4: field Pair (key Pair): 3 elements exceed array length 2
+.env:15: field Servers[1].Port (key Servers[1].Port): required key not found
+.env:10: field Redis.Host (key Redis.Host): required key not found`
+	if err == nil || err.Error() != expected {
+		t.Fatal("incorrect error:", err)
+	}
+
+	delete(source.Map, "ports[2]")
+	delete(source.Map, "pair[2]")
+	source.Map["ports[3]"] = "3"
+	source.Map["servers[1].port"] = "81"
+	source.Map["redis.host"] = "localhost"
+
+	err = reader.New(reader.WithNestedKeys("json")).Read(&configStruct, source)
+
+	if err == nil || err.Error() != ".env:5: field Ports (key Ports): validation failed: length must be at most 2" {
+		t.Fatal("incorrect error:", err)
+	}
+}
+
+// Benchmark_ReadWithNestedCollections        20          19311172 ns/op       5194368 B/op      88076 allocs/op.
+func Benchmark_ReadWithNestedCollections(b *testing.B) {
+	source := reader.Map{}
+	for i := range 2000 {
+		item := "items[" + strconv.Itoa(i) + "]"
+		source[item+".name"] = "item"
+		source[item+".tags[0]"] = "a"
+		source[item+".tags[1]"] = "b"
+		source[item+".labels.team"] = "core"
+	}
+
+	type item struct {
+		Name   string
+		Tags   []string
+		Labels map[string]string
+	}
+
+	benchReader := reader.New(reader.WithNestedKeys("json"))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for range b.N {
+		configStruct := struct{ Items []item }{}
+		if err := benchReader.Read(&configStruct, source); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
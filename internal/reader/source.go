@@ -0,0 +1,99 @@
+package reader
+
+import (
+	"os"
+	"reflect"
+	"strings"
+)
+
+// Source provides the values to be bound to the struct.
+type Source interface {
+	// Lookup returns the value associated to a key, or an empty string if the key is not found.
+	Lookup(key string) string
+
+	// Keys returns all the keys available in the source.
+	Keys() []string
+
+	// Locate returns the source (file name) and line where the key is defined, if known.
+	Locate(key string) (source string, line int)
+}
+
+// DocumentSource is a Source of hierarchical input which also holds the document of each key (`{"port": 80}` for
+// `redis`). With nested keys, values of the types it decodes are bound from the document of their key, instead of
+// the values of their nested keys. Registered parse functions and Decode methods take precedence over documents.
+type DocumentSource interface {
+	Source
+
+	// Document returns the document of a key matched case-insensitively, with the key as found in the source,
+	// or an empty document if the key is not found.
+	Document(key string) (sourceKey, document string)
+
+	// Decodes reports whether values of the type are decoded from documents.
+	Decodes(typ reflect.Type) bool
+
+	// Decode decodes a document into v, which is addressable.
+	Decode(document string, v reflect.Value) error
+}
+
+// ValueReader is read a function that accepts a key and returns its associated value.
+type ValueReader func(*string) string
+
+func (r ValueReader) Lookup(key string) string {
+	return r(&key)
+}
+
+// Keys returns no keys as a ValueReader cannot list them.
+func (r ValueReader) Keys() []string {
+	return nil
+}
+
+// Locate returns no location as a ValueReader does not know it.
+func (r ValueReader) Locate(string) (string, int) {
+	return "", 0
+}
+
+// Map is a Source holding the values in a map.
+type Map map[string]string
+
+func (m Map) Lookup(key string) string {
+	return m[key]
+}
+
+func (m Map) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// Locate returns no location as a Map does not know it.
+func (m Map) Locate(string) (string, int) {
+	return "", 0
+}
+
+// Env is a Source reading the environment variables.
+type Env struct{}
+
+func (Env) Lookup(key string) string {
+	return os.Getenv(key)
+}
+
+func (Env) Keys() []string {
+	environ := os.Environ()
+	keys := make([]string, 0, len(environ))
+
+	for _, variable := range environ {
+		if key, _, ok := strings.Cut(variable, "="); ok {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// Locate returns no location for environment variables.
+func (Env) Locate(string) (string, int) {
+	return "", 0
+}
@@ -0,0 +1,390 @@
+package reader
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	minTag      = "min"
+	maxTag      = "max"
+	lenTag      = "len"
+	oneOfTag    = "oneof"
+	regexpTag   = "regexp"
+	nonEmptyTag = "nonempty"
+
+	oneOfSeparator = "|"
+)
+
+// ErrValidation is the error of a value which does not satisfy the validation tags of its field.
+var ErrValidation = errors.New("validation failed")
+
+var errEmpty = fmt.Errorf("%w: must not be empty", ErrValidation)
+
+// validator is implemented by structs which validate themselves after being populated.
+type validator interface {
+	Validate() error
+}
+
+// Validate calls the Validate method of the struct and of all its nested structs (deepest first),
+// with either value or pointer receiver. The errors are returned as Errors of *StructError.
+//
+// Panics for types different than pointer to a struct.
+func (r *Reader) Validate(structPtr any) error {
+	var errs Errors
+
+	r.validateStruct(reflect.ValueOf(structPtr).Elem(), "", true, &errs)
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// validateStruct calls the Validate method of the nested structs, also of those in slices, arrays and maps
+// (`Servers[0]`, `Routes[api]`), then the one of the struct itself. The method of an embedded struct is not called
+// if it is promoted to the parent struct, as it is called as the method of the parent.
+func (r *Reader) validateStruct(val reflect.Value, path string, self bool, errs *Errors) {
+	typ := val.Type()
+	promoted := promotesValidate(typ)
+
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		if !field.IsExported() || !r.holdsStructs(field.Type) {
+			continue
+		}
+
+		fieldPath := field.Name
+		if path != "" {
+			fieldPath = path + "." + field.Name
+		}
+
+		r.validateValue(val.Field(i), fieldPath, !field.Anonymous || !promoted, errs)
+	}
+
+	if !self {
+		return
+	}
+
+	target := val
+	if val.CanAddr() {
+		target = val.Addr()
+	}
+
+	if v, ok := target.Interface().(validator); ok {
+		if err := v.Validate(); err != nil {
+			*errs = append(*errs, &StructError{Path: path, Err: err})
+		}
+	}
+}
+
+// validateValue validates a struct, the struct it points to, or the structs held by a slice, array or map,
+// located by the index or the key of the element (`Servers[0]`, `Routes[api]`).
+func (r *Reader) validateValue(val reflect.Value, path string, self bool, errs *Errors) {
+	switch val.Kind() {
+	case reflect.Pointer:
+		if !val.IsNil() {
+			r.validateValue(val.Elem(), path, self, errs)
+		}
+	case reflect.Struct:
+		r.validateStruct(val, path, self, errs)
+	case reflect.Slice, reflect.Array:
+		for i := range val.Len() {
+			r.validateValue(val.Index(i), path+"["+strconv.Itoa(i)+"]", true, errs)
+		}
+	case reflect.Map:
+		keys := val.MapKeys()
+		names := make(map[reflect.Value]string, len(keys))
+
+		for _, key := range keys {
+			names[key] = fmt.Sprint(key)
+		}
+
+		sort.Slice(keys, func(i, j int) bool { return names[keys[i]] < names[keys[j]] })
+
+		for _, key := range keys {
+			// Map values are copied to be addressable, for methods with pointer receiver.
+			element := reflect.New(val.Type().Elem()).Elem()
+			element.Set(val.MapIndex(key))
+
+			r.validateValue(element, path+"["+names[key]+"]", true, errs)
+		}
+	default:
+		// Other values do not hold structs.
+	}
+}
+
+// holdsStructs reports whether values of the type are, point to, or hold in slices, arrays and maps
+// structs to validate. Structs decoding themselves are not validated.
+func (r *Reader) holdsStructs(typ reflect.Type) bool {
+	for seen := make(map[reflect.Type]bool); !seen[typ]; typ = typ.Elem() {
+		seen[typ] = true
+
+		switch typ.Kind() {
+		case reflect.Struct:
+			return !r.isDecodable(typ)
+		case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+			// The elements are checked.
+		default:
+			return false
+		}
+	}
+
+	return false
+}
+
+// promotesValidate reports whether the struct type has a Validate method (with value or pointer receiver)
+// promoted from an embedded struct, rather than declared by the type. The methods promoted to a type are
+// wrappers generated by the compiler.
+func promotesValidate(typ reflect.Type) bool {
+	for _, t := range []reflect.Type{typ, reflect.PointerTo(typ)} {
+		method, ok := t.MethodByName("Validate")
+		if !ok {
+			continue
+		}
+
+		fn := runtime.FuncForPC(method.Func.Pointer())
+		if fn == nil {
+			return false
+		}
+
+		file, _ := fn.FileLine(fn.Entry())
+
+		return file == "<autogenerated>"
+	}
+
+	return false
+}
+
+// validate checks the value of a field against its validation tags:
+//   - min, max: bounds of numbers and durations (`min:"1s"`), or of the length of strings, slices, arrays and maps
+//   - len: exact length of strings, slices, arrays and maps
+//   - oneof: allowed values separated by `|` (`oneof:"debug|info|warn"`), checked for each element of collections
+//   - regexp: pattern matched by strings, checked for each element of collections
+//   - nonempty: value must not be empty (`nonempty:"true"`)
+//
+// Bounds and allowed values are converted to the type of the field, so they are written as values would be.
+// Nil pointers are not validated.
+func (r *Reader) validate(field *reflect.StructField, v reflect.Value) error {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+
+		v = v.Elem()
+	}
+
+	if nonEmpty, err := strconv.ParseBool(field.Tag.Get(nonEmptyTag)); err == nil && nonEmpty && isEmpty(v) {
+		return errEmpty
+	}
+
+	if r.isDecodable(v.Type()) || !isCollection(v) {
+		return r.validateScalar(field, v)
+	}
+
+	if err := validateLength(field, v); err != nil {
+		return err
+	}
+
+	return r.validateElements(field, v)
+}
+
+// validateScalar checks the bounds, the allowed values and the pattern of a single value.
+func (r *Reader) validateScalar(field *reflect.StructField, v reflect.Value) error {
+	if err := r.validateBound(field, v, minTag, -1, "at least"); err != nil {
+		return err
+	}
+
+	if err := r.validateBound(field, v, maxTag, 1, "at most"); err != nil {
+		return err
+	}
+
+	if _, hasLen := field.Tag.Lookup(lenTag); hasLen {
+		return fmt.Errorf("%s tag is not supported for type %s", lenTag, v.Type())
+	}
+
+	return r.validateElement(field, v)
+}
+
+// validateBound checks that the value is not beyond the bound given by the tag.
+// Direction is -1 for a lower bound and 1 for an upper bound.
+func (r *Reader) validateBound(
+	field *reflect.StructField,
+	v reflect.Value,
+	tag string,
+	direction int,
+	text string,
+) error {
+	limit, ok := field.Tag.Lookup(tag)
+	if !ok {
+		return nil
+	}
+
+	if !isNumber(v) {
+		return fmt.Errorf("%s tag is not supported for type %s", tag, v.Type())
+	}
+
+	bound := reflect.New(v.Type()).Elem()
+	if err := r.decode(field, bound, limit); err != nil {
+		return fmt.Errorf("invalid %s tag %q: %w", tag, limit, err)
+	}
+
+	if compare(v, bound) == direction {
+		return fmt.Errorf("%w: must be %s %s", ErrValidation, text, limit)
+	}
+
+	return nil
+}
+
+// validateLength checks the length of a string, slice, array or map.
+// The length of a string is its number of characters.
+func validateLength(field *reflect.StructField, v reflect.Value) error {
+	length := v.Len()
+	if v.Kind() == reflect.String {
+		length = utf8.RuneCountInString(v.String())
+	}
+
+	checks := []struct {
+		tag   string
+		fails func(limit int) bool
+		text  string
+	}{
+		{lenTag, func(limit int) bool { return length != limit }, "exactly"},
+		{minTag, func(limit int) bool { return length < limit }, "at least"},
+		{maxTag, func(limit int) bool { return length > limit }, "at most"},
+	}
+
+	for _, check := range checks {
+		tagValue, ok := field.Tag.Lookup(check.tag)
+		if !ok {
+			continue
+		}
+
+		limit, err := strconv.Atoi(tagValue)
+		if err != nil {
+			return fmt.Errorf("invalid %s tag %q: %w", check.tag, tagValue, err)
+		}
+
+		if check.fails(limit) {
+			return fmt.Errorf("%w: length must be %s %d", ErrValidation, check.text, limit)
+		}
+	}
+
+	return nil
+}
+
+// validateElements checks the allowed values and the pattern of each element of a slice, array or map.
+// Strings are checked as a whole.
+func (r *Reader) validateElements(field *reflect.StructField, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			if err := r.validateElement(field, v.Index(i)); err != nil {
+				return &elementError{index: strconv.Itoa(i), err: err}
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := r.validateElement(field, iter.Value()); err != nil {
+				return &elementError{index: fmt.Sprint(iter.Key()), err: err}
+			}
+		}
+	default:
+		return r.validateElement(field, v)
+	}
+
+	return nil
+}
+
+// validateElement checks that a value is one of the allowed values and matches the pattern.
+func (r *Reader) validateElement(field *reflect.StructField, v reflect.Value) error {
+	if options, ok := field.Tag.Lookup(oneOfTag); ok {
+		if err := r.validateOneOf(field, v, options); err != nil {
+			return err
+		}
+	}
+
+	if pattern, ok := field.Tag.Lookup(regexpTag); ok {
+		if v.Kind() != reflect.String {
+			return fmt.Errorf("%s tag is not supported for type %s", regexpTag, v.Type())
+		}
+
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid %s tag: %w", regexpTag, err)
+		}
+
+		if !re.MatchString(v.String()) {
+			return fmt.Errorf("%w: must match %s", ErrValidation, pattern)
+		}
+	}
+
+	return nil
+}
+
+func (r *Reader) validateOneOf(field *reflect.StructField, v reflect.Value, options string) error {
+	for _, option := range strings.Split(options, oneOfSeparator) {
+		allowed := reflect.New(v.Type()).Elem()
+		if err := r.decode(field, allowed, option); err != nil {
+			return fmt.Errorf("invalid %s tag option %q: %w", oneOfTag, option, err)
+		}
+
+		if reflect.DeepEqual(v.Interface(), allowed.Interface()) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: must be one of %s", ErrValidation, options)
+}
+
+// isCollection reports whether the value is a string, slice, array or map, which are validated by their length.
+func isCollection(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		return true
+	default:
+		return false
+	}
+}
+
+func isNumber(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// isEmpty reports whether a collection has no elements or another value is the zero value.
+func isEmpty(v reflect.Value) bool {
+	if isCollection(v) {
+		return v.Len() == 0
+	}
+
+	return v.IsZero()
+}
+
+// compare returns -1, 0 or 1 if the number a is less than, equal to or greater than the number b.
+func compare(a, b reflect.Value) int {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp.Compare(a.Int(), b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cmp.Compare(a.Uint(), b.Uint())
+	default:
+		return cmp.Compare(a.Float(), b.Float())
+	}
+}
@@ -0,0 +1,704 @@
+// Package toml parses TOML into variables keyed by the path of each value.
+//
+// All of TOML 1.0 is supported: tables, arrays of tables, dotted and quoted keys, inline tables, arrays,
+// basic, literal and multiline strings, integers (also hexadecimal, octal and binary), floats, booleans and datetimes.
+package toml
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/andreiavrammsd/config/internal/parser"
+)
+
+// Kinds of the keys defined in the document, to detect redefinitions.
+const (
+	kindValue = iota + 1
+	kindTable
+	kindImplicitTable
+	kindDottedTable
+	kindInlineTable
+	kindArrayOfTables
+)
+
+// Layouts of the datetimes, without the fractional seconds which are accepted anyway.
+const (
+	offsetDateTimeLayout = "2006-01-02T15:04:05Z07:00"
+	localDateTimeLayout  = "2006-01-02T15:04:05"
+	localDateLayout      = "2006-01-02"
+	localTimeLayout      = "15:04:05"
+)
+
+var (
+	decimalPattern = regexp.MustCompile(`^[+-]?(0|[1-9](_?[0-9])*)$`)
+	prefixPattern  = regexp.MustCompile(`^(0x[0-9A-Fa-f](_?[0-9A-Fa-f])*|0o[0-7](_?[0-7])*|0b[01](_?[01])*)$`)
+	floatPattern   = regexp.MustCompile(
+		`^[+-]?(0|[1-9](_?[0-9])*)(\.[0-9](_?[0-9])*)?([eE][+-]?[0-9](_?[0-9])*)?$|^[+-]?(inf|nan)$`,
+	)
+	datePattern = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}$`)
+)
+
+// Characters escaped by a backslash in basic strings.
+var escapes = map[byte]string{
+	'b': "\b", 't': "\t", 'n': "\n", 'f': "\f", 'r': "\r", 'e': "\x1b", '"': "\"", '\\': "\\",
+}
+
+// Number of hexadecimal digits of the `\uXXXX` and `\UXXXXXXXX` escape sequences.
+var unicodeEscapes = map[byte]int{'u': 4, 'U': 8}
+
+type document struct {
+	file      string
+	input     string
+	i         int
+	vars      map[string]string
+	positions map[string]parser.Position
+	literals  map[string]bool
+
+	// Line of the current character, and index where the line starts.
+	line      int
+	lineStart int
+
+	// Path of the current table (`servers[1].tls`), empty for the root table.
+	table string
+
+	// Kinds of the keys and tables defined.
+	defined map[string]int
+
+	// Number of tables of each array of tables.
+	arrays map[string]int
+}
+
+// Parse reads a TOML document and adds to the vars map a variable for each value, keyed by its path:
+// the keys of the tables joined by dots and the indexes of arrays and arrays of tables (`servers[0].host`).
+// Datetimes are converted to RFC 3339 (`1979-05-27T07:32:00Z`), local ones in UTC, and integers to decimal.
+// If positions is not nil, the position of each variable is added to it.
+// If literals is not nil, the keys of literal strings (single-quoted, also multiline), which must not be
+// interpolated, are added to it.
+// If the reader has a name (like *os.File), it is used as file of the positions.
+//
+// The first problem found is returned as *parser.ParseError.
+func Parse(
+	r io.Reader, vars map[string]string, positions map[string]parser.Position, literals map[string]bool,
+) error {
+	input, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	d := &document{
+		input:     string(bytes.ReplaceAll(input, []byte("\r\n"), []byte("\n"))),
+		vars:      vars,
+		positions: positions,
+		literals:  literals,
+		line:      1,
+		defined:   make(map[string]int),
+		arrays:    make(map[string]int),
+	}
+
+	if named, ok := r.(interface{ Name() string }); ok {
+		d.file = named.Name()
+	}
+
+	if !utf8.ValidString(d.input) {
+		return d.errorf("invalid UTF-8")
+	}
+
+	for {
+		d.skipBlank()
+
+		if d.done() {
+			return nil
+		}
+
+		if d.peek() == '[' {
+			err = d.header()
+		} else {
+			err = d.keyValue(d.table)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := d.lineEnd(); err != nil {
+			return err
+		}
+	}
+}
+
+func (d *document) done() bool {
+	return d.i >= len(d.input)
+}
+
+// peek returns the current character, or 0 at the end of the input.
+func (d *document) peek() byte {
+	if d.done() {
+		return 0
+	}
+
+	return d.input[d.i]
+}
+
+func (d *document) advance() {
+	if d.input[d.i] == '\n' {
+		d.line++
+		d.lineStart = d.i + 1
+	}
+
+	d.i++
+}
+
+func (d *document) consume(prefix string) bool {
+	if !strings.HasPrefix(d.input[d.i:], prefix) {
+		return false
+	}
+
+	for range len(prefix) {
+		d.advance()
+	}
+
+	return true
+}
+
+func (d *document) skipSpaces() {
+	for d.peek() == ' ' || d.peek() == '\t' {
+		d.advance()
+	}
+}
+
+// skipBlank advances past whitespace, line ends and comments.
+func (d *document) skipBlank() {
+	for {
+		d.skipSpaces()
+
+		switch d.peek() {
+		case '\n':
+			d.advance()
+		case '#':
+			d.skipComment()
+		default:
+			return
+		}
+	}
+}
+
+func (d *document) skipComment() {
+	for !d.done() && d.peek() != '\n' {
+		d.advance()
+	}
+}
+
+// lineEnd reads the end of a line, after a key/value pair or a table header.
+func (d *document) lineEnd() error {
+	d.skipSpaces()
+
+	if d.peek() == '#' {
+		d.skipComment()
+	}
+
+	if !d.done() && d.peek() != '\n' {
+		return d.errorf("expected end of line")
+	}
+
+	return nil
+}
+
+func (d *document) position() parser.Position {
+	return parser.Position{
+		File:   d.file,
+		Line:   d.line,
+		Column: utf8.RuneCountInString(d.input[d.lineStart:d.i]) + 1,
+	}
+}
+
+// errorf returns a *parser.ParseError at the current position.
+func (d *document) errorf(format string, args ...any) error {
+	return errorAt(d.position(), format, args...)
+}
+
+func errorAt(pos parser.Position, format string, args ...any) error {
+	return &parser.ParseError{File: pos.File, Line: pos.Line, Column: pos.Column, Msg: fmt.Sprintf(format, args...)}
+}
+
+// header reads a table header (`[table]`) or an array of tables header (`[[table]]`).
+func (d *document) header() error {
+	pos := d.position()
+
+	d.advance()
+	array := d.consume("[")
+	d.skipSpaces()
+
+	keys, err := d.key()
+	if err != nil {
+		return err
+	}
+
+	d.skipSpaces()
+
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+
+	if !d.consume(closing) {
+		return d.errorf("expected %q", closing)
+	}
+
+	path, err := d.path("", keys, kindImplicitTable)
+	if err != nil {
+		return errorAt(pos, "%s", err)
+	}
+
+	if array {
+		if kind := d.defined[path]; kind != 0 && kind != kindArrayOfTables {
+			return errorAt(pos, "key %q already defined", path)
+		}
+
+		d.defined[path] = kindArrayOfTables
+		d.table = path + "[" + strconv.Itoa(d.arrays[path]) + "]"
+		d.arrays[path]++
+
+		return nil
+	}
+
+	if kind := d.defined[path]; kind != 0 && kind != kindImplicitTable {
+		return errorAt(pos, "table %q already defined", path)
+	}
+
+	d.defined[path] = kindTable
+	d.table = path
+
+	return nil
+}
+
+// path resolves the keys to a path from the given table, defining the intermediate tables of the given kind,
+// and appends the last key. Keys of arrays of tables refer to their last table.
+// Inline tables cannot be extended, nor can dotted keys (kindDottedTable) extend tables defined by headers.
+func (d *document) path(table string, keys []string, kind int) (string, error) {
+	path := table
+
+	for i, key := range keys {
+		if path != "" {
+			path += "."
+		}
+
+		path += key
+
+		if i == len(keys)-1 {
+			break
+		}
+
+		switch d.defined[path] {
+		case kindValue:
+			return "", fmt.Errorf("key %q is not a table", path)
+		case kindInlineTable:
+			return "", fmt.Errorf("inline table %q cannot be extended", path)
+		case kindTable:
+			if kind == kindDottedTable {
+				return "", fmt.Errorf("table %q cannot be extended by dotted keys", path)
+			}
+		case kindArrayOfTables:
+			path += "[" + strconv.Itoa(d.arrays[path]-1) + "]"
+		case 0:
+			d.defined[path] = kind
+		}
+	}
+
+	return path, nil
+}
+
+// key reads a key made of simple keys separated by dots (`a."b.c".d`).
+func (d *document) key() ([]string, error) {
+	var keys []string
+
+	for {
+		d.skipSpaces()
+
+		key, err := d.simpleKey()
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+
+		d.skipSpaces()
+
+		if d.peek() != '.' {
+			return keys, nil
+		}
+
+		d.advance()
+	}
+}
+
+// simpleKey reads a bare key (`key_1-a`) or a quoted key (`"key"`, `'key'`).
+func (d *document) simpleKey() (string, error) {
+	switch d.peek() {
+	case '"':
+		return d.basicString()
+	case '\'':
+		return d.literalString()
+	}
+
+	start := d.i
+	for isBareKeyCharacter(d.peek()) {
+		d.advance()
+	}
+
+	if d.i == start {
+		return "", d.errorf("expected key")
+	}
+
+	return d.input[start:d.i], nil
+}
+
+func isBareKeyCharacter(c byte) bool {
+	return c == '_' || c == '-' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// keyValue reads a key/value pair (`key = value`) of the given table.
+func (d *document) keyValue(table string) error {
+	pos := d.position()
+
+	keys, err := d.key()
+	if err != nil {
+		return err
+	}
+
+	path, err := d.path(table, keys, kindDottedTable)
+	if err != nil {
+		return errorAt(pos, "%s", err)
+	}
+
+	if d.defined[path] != 0 {
+		return errorAt(pos, "key %q already defined", path)
+	}
+
+	d.defined[path] = kindValue
+
+	d.skipSpaces()
+
+	if !d.consume("=") {
+		return d.errorf("expected '=' after key")
+	}
+
+	d.skipSpaces()
+
+	return d.value(path)
+}
+
+// value reads a value and sets the variables of its path.
+func (d *document) value(path string) error {
+	pos := d.position()
+
+	var (
+		value   string
+		literal bool
+		err     error
+	)
+
+	switch {
+	case d.consume(`"""`):
+		value, err = d.multilineString(`"""`, true)
+	case d.consume(`'''`):
+		value, err = d.multilineString(`'''`, false)
+		literal = true
+	case d.peek() == '"':
+		value, err = d.basicString()
+	case d.peek() == '\'':
+		value, err = d.literalString()
+		literal = true
+	case d.peek() == '[':
+		return d.array(path)
+	case d.peek() == '{':
+		return d.inlineTable(path)
+	default:
+		value, err = d.scalar()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	d.vars[path] = value
+
+	if d.positions != nil {
+		d.positions[path] = pos
+	}
+
+	if d.literals != nil && literal {
+		d.literals[path] = true
+	}
+
+	return nil
+}
+
+// array reads the values of an array (`[1, 2]`), which can span multiple lines.
+func (d *document) array(path string) error {
+	d.advance()
+
+	for index := 0; ; index++ {
+		d.skipBlank()
+
+		if d.consume("]") {
+			return nil
+		}
+
+		if err := d.value(path + "[" + strconv.Itoa(index) + "]"); err != nil {
+			return err
+		}
+
+		d.skipBlank()
+
+		if d.consume("]") {
+			return nil
+		}
+
+		if !d.consume(",") {
+			return d.errorf("expected ',' or ']'")
+		}
+	}
+}
+
+// inlineTable reads the key/value pairs of an inline table (`{a = 1, b = 2}`).
+func (d *document) inlineTable(path string) error {
+	d.advance()
+	d.defined[path] = kindInlineTable
+
+	for {
+		d.skipBlank()
+
+		if d.consume("}") {
+			return nil
+		}
+
+		if err := d.keyValue(path); err != nil {
+			return err
+		}
+
+		d.skipBlank()
+
+		if d.consume("}") {
+			return nil
+		}
+
+		if !d.consume(",") {
+			return d.errorf("expected ',' or '}'")
+		}
+	}
+}
+
+// basicString reads a string between double quotes, with escape sequences (`"a\tb"`).
+func (d *document) basicString() (string, error) {
+	d.advance()
+
+	var b strings.Builder
+
+	for {
+		switch c := d.peek(); {
+		case d.done() || c == '\n':
+			return "", d.errorf("unterminated string")
+		case c == '"':
+			d.advance()
+			return b.String(), nil
+		case c == '\\':
+			if err := d.escape(&b); err != nil {
+				return "", err
+			}
+		case isControl(c):
+			return "", d.errorf("control character in string")
+		default:
+			b.WriteByte(c)
+			d.advance()
+		}
+	}
+}
+
+// literalString reads a string between single quotes, without escape sequences (`'C:\path'`).
+func (d *document) literalString() (string, error) {
+	d.advance()
+
+	start := d.i
+
+	for {
+		switch c := d.peek(); {
+		case d.done() || c == '\n':
+			return "", d.errorf("unterminated string")
+		case c == '\'':
+			value := d.input[start:d.i]
+			d.advance()
+
+			return value, nil
+		case isControl(c):
+			return "", d.errorf("control character in string")
+		default:
+			d.advance()
+		}
+	}
+}
+
+// multilineString reads a string spanning multiple lines, after its opening delimiter of three quotes.
+// A line end right after the opening delimiter is trimmed. In basic strings, escape sequences are processed
+// and a backslash at the end of a line trims the whitespace up to the next character.
+func (d *document) multilineString(delimiter string, basic bool) (string, error) {
+	d.consume("\n")
+
+	var b strings.Builder
+
+	for {
+		switch c := d.peek(); {
+		case d.done():
+			return "", d.errorf("unterminated string")
+		case strings.HasPrefix(d.input[d.i:], delimiter):
+			d.consume(delimiter)
+
+			// Up to two quotes right before the closing delimiter are part of the string.
+			for range 2 {
+				if d.consume(delimiter[:1]) {
+					b.WriteByte(delimiter[0])
+				}
+			}
+
+			return b.String(), nil
+		case basic && c == '\\' && d.atLineEndingBackslash():
+			d.advance()
+
+			for !d.done() && strings.IndexByte(" \t\n", d.peek()) != -1 {
+				d.advance()
+			}
+		case basic && c == '\\':
+			if err := d.escape(&b); err != nil {
+				return "", err
+			}
+		case isControl(c) && c != '\n':
+			return "", d.errorf("control character in string")
+		default:
+			b.WriteByte(c)
+			d.advance()
+		}
+	}
+}
+
+// atLineEndingBackslash reports whether the current backslash is followed only by whitespace up to the line end.
+func (d *document) atLineEndingBackslash() bool {
+	rest := d.input[d.i+1:]
+	if end := strings.IndexByte(rest, '\n'); end != -1 {
+		return strings.Trim(rest[:end], " \t") == ""
+	}
+
+	return false
+}
+
+// escape writes the character of the escape sequence starting with the current backslash.
+func (d *document) escape(b *strings.Builder) error {
+	d.advance()
+
+	c := d.peek()
+	if s, ok := escapes[c]; ok {
+		b.WriteString(s)
+		d.advance()
+
+		return nil
+	}
+
+	length, ok := unicodeEscapes[c]
+	if !ok || d.i+1+length > len(d.input) {
+		return d.errorf("invalid escape sequence")
+	}
+
+	code, err := strconv.ParseUint(d.input[d.i+1:d.i+1+length], 16, 32)
+	if err != nil || !utf8.ValidRune(rune(code)) {
+		return d.errorf("invalid escape sequence")
+	}
+
+	b.WriteRune(rune(code))
+
+	for range 1 + length {
+		d.advance()
+	}
+
+	return nil
+}
+
+func isControl(c byte) bool {
+	return c < 0x20 && c != '\t' || c == 0x7f
+}
+
+// scalar reads a boolean, a number or a datetime.
+func (d *document) scalar() (string, error) {
+	pos := d.position()
+	start := d.i
+	for !d.done() && !strings.ContainsRune(" \t\n,]}#", rune(d.peek())) {
+		d.advance()
+	}
+
+	token := d.input[start:d.i]
+
+	// Date and time separated by space (`1979-05-27 07:32:00`).
+	if datePattern.MatchString(token) && d.peek() == ' ' && d.i+3 < len(d.input) &&
+		isDigit(d.input[d.i+1]) && isDigit(d.input[d.i+2]) && d.input[d.i+3] == ':' {
+		d.advance()
+
+		for !d.done() && !strings.ContainsRune(" \t\n,]}#", rune(d.peek())) {
+			d.advance()
+		}
+
+		token = token + "T" + d.input[start+len(token)+1:d.i]
+	}
+
+	switch {
+	case token == "true" || token == "false":
+		return token, nil
+	case decimalPattern.MatchString(token):
+		return strings.ReplaceAll(token, "_", ""), nil
+	case prefixPattern.MatchString(token):
+		value, err := strconv.ParseUint(token, 0, 64)
+		if err != nil {
+			return "", errorAt(pos, "invalid integer %q", token)
+		}
+
+		return strconv.FormatUint(value, 10), nil
+	case floatPattern.MatchString(token):
+		return strings.ReplaceAll(token, "_", ""), nil
+	}
+
+	if value, ok := datetime(token); ok {
+		return value, nil
+	}
+
+	if token == "" {
+		return "", errorAt(pos, "expected value")
+	}
+
+	return "", errorAt(pos, "invalid value %q", token)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// datetime converts an offset datetime, a local datetime, a local date or a local time to RFC 3339.
+// Local values are in UTC.
+func datetime(token string) (string, bool) {
+	if len(token) > len(localDateLayout) && (token[len(localDateLayout)] == 't') {
+		token = token[:len(localDateLayout)] + "T" + token[len(localDateLayout)+1:]
+	}
+
+	if strings.HasSuffix(token, "z") {
+		token = token[:len(token)-1] + "Z"
+	}
+
+	for _, layout := range []string{offsetDateTimeLayout, localDateTimeLayout, localDateLayout, localTimeLayout} {
+		if t, err := time.Parse(layout, token); err == nil {
+			return t.Format(time.RFC3339Nano), true
+		}
+	}
+
+	return "", false
+}
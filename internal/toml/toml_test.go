@@ -0,0 +1,315 @@
+package toml_test
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/andreiavrammsd/config/internal/parser"
+	"github.com/andreiavrammsd/config/internal/toml"
+)
+
+func parse(t *testing.T, input string) map[string]string {
+	t.Helper()
+
+	vars := make(map[string]string)
+	if err := toml.Parse(strings.NewReader(input), vars, nil, nil); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	return vars
+}
+
+func assertVars(t *testing.T, actual, expected map[string]string) {
+	t.Helper()
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("\nhave: %q\nwant: %q", actual, expected)
+	}
+}
+
+func TestParseTables(t *testing.T) {
+	vars := parse(t, `# comment
+name = "api" # comment
+"quoted key" = 1
+'literal.key' = 2
+site."google.com" = true
+a . b . c = 3
+
+[redis]
+host = "localhost"
+port = 6379
+
+[redis.tls]
+enabled = true
+cipher.name = "aes"
+
+[redis.tls.cipher.options]
+strict = true
+
+[ database . primary ]
+url = "postgres://host/db"
+
+[[servers]]
+host = "one"
+
+[servers.tls]
+cert = "a.pem"
+
+[[servers]]
+host = "two"
+
+[[servers.tags]]
+name = "x"
+`)
+
+	assertVars(t, vars, map[string]string{
+		"name":                            "api",
+		"quoted key":                      "1",
+		"literal.key":                     "2",
+		"site.google.com":                 "true",
+		"a.b.c":                           "3",
+		"redis.host":                      "localhost",
+		"redis.port":                      "6379",
+		"redis.tls.enabled":               "true",
+		"redis.tls.cipher.name":           "aes",
+		"redis.tls.cipher.options.strict": "true",
+		"database.primary.url":            "postgres://host/db",
+		"servers[0].host":                 "one",
+		"servers[0].tls.cert":             "a.pem",
+		"servers[1].host":                 "two",
+		"servers[1].tags[0].name":         "x",
+	})
+}
+
+func TestParseArraysAndInlineTables(t *testing.T) {
+	vars := parse(t, `ports = [80, 443, ]
+hosts = [
+  "a", # comment
+  'b',
+]
+matrix = [[1, 2], [3]]
+empty = []
+labels = { team = "core", env.name = "prod" }
+servers = [{ host = "a" }, { host = "b", port = 1 }]
+`)
+
+	assertVars(t, vars, map[string]string{
+		"ports[0]":        "80",
+		"ports[1]":        "443",
+		"hosts[0]":        "a",
+		"hosts[1]":        "b",
+		"matrix[0][0]":    "1",
+		"matrix[0][1]":    "2",
+		"matrix[1][0]":    "3",
+		"labels.team":     "core",
+		"labels.env.name": "prod",
+		"servers[0].host": "a",
+		"servers[1].host": "b",
+		"servers[1].port": "1",
+	})
+}
+
+func TestParseStrings(t *testing.T) {
+	vars := parse(t, `basic = "tab\there \"quoted\" \u00fc \U0001F680 # not a comment"
+literal = 'C:\path\$HOME'
+multiline = """
+first
+second"""
+trimmed = """\
+  one \
+  two"""
+quotes = """a ""quoted"" b"""""
+multiline_literal = '''
+raw \n
+'''
+empty = ""
+emoji = "🚀 ü"
+`)
+
+	assertVars(t, vars, map[string]string{
+		"basic":             "tab\there \"quoted\" ü 🚀 # not a comment",
+		"literal":           `C:\path\$HOME`,
+		"multiline":         "first\nsecond",
+		"trimmed":           "one two",
+		"quotes":            `a ""quoted"" b""`,
+		"multiline_literal": "raw \\n\n",
+		"empty":             "",
+		"emoji":             "🚀 ü",
+	})
+}
+
+func TestParseLiterals(t *testing.T) {
+	input := `basic = "${A}"
+literal = '${A}'
+multiline_literal = '''${A}'''
+array = ['a', "b"]
+`
+	vars := make(map[string]string)
+	literals := make(map[string]bool)
+
+	if err := toml.Parse(strings.NewReader(input), vars, nil, literals); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	expected := map[string]bool{"literal": true, "multiline_literal": true, "array[0]": true}
+	if !reflect.DeepEqual(literals, expected) {
+		t.Fatalf("\nhave: %v\nwant: %v", literals, expected)
+	}
+}
+
+func TestParseNumbersAndDatetimes(t *testing.T) {
+	vars := parse(t, `int = +1_000
+negative = -17
+hex = 0xdead_BEEF
+oct = 0o755
+bin = 0b1101
+float = 6.626e-34
+underscores = 224_617.445_991
+inf = -inf
+nan = nan
+bool = false
+offset = 1979-05-27T07:32:00-08:00
+utc = 1979-05-27 07:32:00.999Z
+local = 1979-05-27T07:32:00
+date = 1979-05-27
+time = 07:32:00
+`)
+
+	assertVars(t, vars, map[string]string{
+		"int":         "+1000",
+		"negative":    "-17",
+		"hex":         "3735928559",
+		"oct":         "493",
+		"bin":         "13",
+		"float":       "6.626e-34",
+		"underscores": "224617.445991",
+		"inf":         "-inf",
+		"nan":         "nan",
+		"bool":        "false",
+		"offset":      "1979-05-27T07:32:00-08:00",
+		"utc":         "1979-05-27T07:32:00.999Z",
+		"local":       "1979-05-27T07:32:00Z",
+		"date":        "1979-05-27T00:00:00Z",
+		"time":        "0000-01-01T07:32:00Z",
+	})
+}
+
+func TestParseWithPositions(t *testing.T) {
+	file, err := os.CreateTemp(t.TempDir(), "*.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := file.WriteString("a = 1\n[b]\nc = 'x'\nd = [\n  3,\n]\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := file.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	defer file.Close()
+
+	vars := make(map[string]string)
+	positions := make(map[string]parser.Position)
+
+	if err := toml.Parse(file, vars, positions, nil); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	expected := map[string]parser.Position{
+		"a":      {File: file.Name(), Line: 1, Column: 5},
+		"b.c":    {File: file.Name(), Line: 3, Column: 5},
+		"b.d[0]": {File: file.Name(), Line: 5, Column: 3},
+	}
+
+	if !reflect.DeepEqual(positions, expected) {
+		t.Fatalf("\nhave: %v\nwant: %v", positions, expected)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		expected string
+	}{
+		"missing equals":   {"a 1", "line 1, column 3: expected '=' after key"},
+		"missing key":      {"= 1", "line 1, column 1: expected key"},
+		"missing value":    {"a =", "line 1, column 4: expected value"},
+		"invalid value":    {"a = yes", `line 1, column 5: invalid value "yes"`},
+		"leading zero":     {"a = 01", `line 1, column 5: invalid value "01"`},
+		"line end":         {"a = 1 2", "line 1, column 7: expected end of line"},
+		"duplicate key":    {"a = 1\na = 2", `line 2, column 1: key "a" already defined`},
+		"duplicate table":  {"[a]\n[a]", `line 2, column 1: table "a" already defined`},
+		"table of value":   {"a = 1\n[a.b]", `line 2, column 1: key "a" is not a table`},
+		"array of value":   {"a = []\n[[a]]", `line 2, column 1: key "a" already defined`},
+		"table of dotted":  {"a.b = 1\n[a]", `line 2, column 1: table "a" already defined`},
+		"dotted of table":  {"[a.b]\n[a]\nb.c = 1", `line 3, column 1: table "a.b" cannot be extended by dotted keys`},
+		"inline extended":  {"t = {x = 1}\nt.y = 2", `line 2, column 1: inline table "t" cannot be extended`},
+		"inline subtable":  {"t = {x = 1}\n[t.u]", `line 2, column 1: inline table "t" cannot be extended`},
+		"header":           {"[a", `line 1, column 3: expected "]"`},
+		"unterminated":     {`a = "value`, "line 1, column 11: unterminated string"},
+		"newline":          {"a = 'value\n'", "line 1, column 11: unterminated string"},
+		"multiline":        {`a = """value`, "line 1, column 13: unterminated string"},
+		"escape":           {`a = "\q"`, "line 1, column 7: invalid escape sequence"},
+		"unicode escape":   {`a = "\uD800"`, "line 1, column 7: invalid escape sequence"},
+		"control":          {"a = \"\x01\"", "line 1, column 6: control character in string"},
+		"array separator":  {"a = [1 2]", "line 1, column 8: expected ',' or ']'"},
+		"inline separator": {"a = {b = 1 c = 2}", "line 1, column 12: expected ',' or '}'"},
+		"inline duplicate": {"a = {b = 1, b = 2}", `line 1, column 13: key "a.b" already defined`},
+		"large integer":    {"a = 0xffffffffffffffffff", `line 1, column 5: invalid integer "0xffffffffffffffffff"`},
+		"utf-8":            {"a = \"\xff\"", "line 1, column 1: invalid UTF-8"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := toml.Parse(strings.NewReader(test.input), make(map[string]string), nil, nil)
+
+			var parseErr *parser.ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatal("incorrect error:", err)
+			}
+
+			if err.Error() != test.expected {
+				t.Fatalf("incorrect error message: %q", err)
+			}
+		})
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	assertVars(t, parse(t, ""), map[string]string{})
+	assertVars(t, parse(t, "# comment\n\n[table]\n"), map[string]string{})
+}
+
+func FuzzParse(f *testing.F) {
+	testcases := []string{
+		"a = 1\n[b]\nc = [1, {d = 'e'}]\n",
+		"[[a]]\nb = \"\"\"\ntext\"\"\"\n",
+		"a = 1979-05-27 07:32:00Z",
+		`a = "ü"`,
+		"",
+	}
+	for _, tc := range testcases {
+		f.Add(tc)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		varsFirst := make(map[string]string)
+		errFirst := toml.Parse(strings.NewReader(input), varsFirst, nil, nil)
+
+		varsSecond := make(map[string]string)
+		errSecond := toml.Parse(strings.NewReader(input), varsSecond, nil, nil)
+
+		if (errFirst == nil) != (errSecond == nil) {
+			t.Errorf("Before: %v, after: %v", errFirst, errSecond)
+		}
+
+		if !reflect.DeepEqual(varsFirst, varsSecond) {
+			t.Error("Different results")
+		}
+	})
+}
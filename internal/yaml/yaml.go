@@ -0,0 +1,867 @@
+// Package yaml parses a subset of YAML into variables keyed by the path of each value.
+//
+// Supported: block mappings and sequences (nested by indentation, also `- key: value` items), flow mappings and
+// sequences (`{a: 1}`, `[1, 2]`, also spanning multiple lines), plain, single-quoted and double-quoted scalars,
+// multiline plain scalars, literal (`|`) and folded (`>`) block scalars with chomping indicators, comments
+// and a single document, optionally starting with `---`.
+// Not supported: anchors, aliases, tags, complex keys, multiline quoted scalars and multiple documents.
+package yaml
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/andreiavrammsd/config/internal/parser"
+)
+
+const (
+	documentStart = "---"
+	documentEnd   = "..."
+)
+
+// errIncomplete is the error of a flow collection which continues on the next line.
+var errIncomplete = errors.New("unterminated flow collection")
+
+// line is a line of the input.
+type line struct {
+	number int
+
+	// Number of spaces before the text.
+	indent int
+
+	// Text after the indentation, without trailing spaces.
+	text string
+
+	// Text including the indentation.
+	raw string
+}
+
+// isBlank reports whether the line has no content (empty, only spaces or a comment).
+func (l *line) isBlank() bool {
+	return strings.TrimSpace(l.text) == "" || isComment(l.text)
+}
+
+// entry is a value found in a flow collection.
+type entry struct {
+	key   string
+	value string
+}
+
+type document struct {
+	file      string
+	lines     []line
+	i         int
+	vars      map[string]string
+	positions map[string]parser.Position
+
+	// Lines where the keys of mappings are defined, to detect duplicates.
+	defined map[string]int
+}
+
+// Parse reads a YAML document, whose root must be a mapping, and adds to the vars map a variable for each scalar,
+// keyed by its path: the keys of the mappings joined by dots and the indexes of sequences (`servers[0].host`).
+// Null values are skipped. If positions is not nil, the position of each variable is added to it.
+// If the reader has a name (like *os.File), it is used as file of the positions.
+//
+// The first problem found is returned as *parser.ParseError.
+func Parse(r io.Reader, vars map[string]string, positions map[string]parser.Position) error {
+	d := &document{vars: vars, positions: positions, defined: make(map[string]int)}
+
+	if named, ok := r.(interface{ Name() string }); ok {
+		d.file = named.Name()
+	}
+
+	if err := d.read(r); err != nil {
+		return err
+	}
+
+	d.skipBlank()
+
+	// Directives and document start marker.
+	for !d.done() && (strings.HasPrefix(d.current().text, "%") || isMarker(d.current().text, documentStart)) {
+		d.i++
+		d.skipBlank()
+	}
+
+	if d.done() {
+		return nil
+	}
+
+	root := d.current()
+	if !isEntry(root.text) {
+		return d.errorf(root, root.indent+1, "mapping expected")
+	}
+
+	if err := d.mapping(root.indent, ""); err != nil {
+		return err
+	}
+
+	d.skipBlank()
+
+	switch {
+	case d.done(), isMarker(d.current().text, documentEnd):
+		return nil
+	case isMarker(d.current().text, documentStart):
+		return d.errorf(d.current(), 1, "multiple documents are not supported")
+	default:
+		return d.errorf(d.current(), d.current().indent+1, "unexpected indentation")
+	}
+}
+
+// read splits the input into lines.
+func (d *document) read(r io.Reader) error {
+	reader := bufio.NewReader(r)
+
+	for number := 1; ; number++ {
+		raw, err := reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("%w", err)
+		}
+
+		if raw == "" && err != nil {
+			return nil
+		}
+
+		raw = strings.TrimRight(raw, "\r\n")
+		text := strings.TrimLeft(raw, " ")
+
+		d.lines = append(d.lines, line{
+			number: number,
+			indent: len(raw) - len(text),
+			text:   strings.TrimRight(text, " \t"),
+			raw:    raw,
+		})
+	}
+}
+
+func (d *document) done() bool {
+	return d.i >= len(d.lines)
+}
+
+func (d *document) current() line {
+	return d.lines[d.i]
+}
+
+// skipBlank advances past empty lines and comments.
+func (d *document) skipBlank() {
+	for !d.done() && d.lines[d.i].isBlank() {
+		d.i++
+	}
+}
+
+// mapping reads the entries of a block mapping indented by the given number of spaces.
+func (d *document) mapping(indent int, prefix string) error {
+	for {
+		d.skipBlank()
+
+		if d.done() {
+			return nil
+		}
+
+		l := d.current()
+
+		switch {
+		case l.indent < indent, isMarker(l.text, documentStart), isMarker(l.text, documentEnd):
+			return nil
+		case l.indent > indent:
+			return d.errorf(l, l.indent+1, "unexpected indentation")
+		case strings.HasPrefix(l.text, "\t"):
+			return d.errorf(l, l.indent+1, "tabs are not allowed in indentation")
+		case isItem(l.text):
+			return d.errorf(l, l.indent+1, "unexpected sequence item")
+		}
+
+		key, rest, ok := splitEntry(l.text)
+		if !ok {
+			return d.errorf(l, l.indent+1, `expected "key: value"`)
+		}
+
+		name := prefix + key
+		if definedLine, exists := d.defined[name]; exists {
+			return d.errorf(l, l.indent+1, fmt.Sprintf("key %q already defined at line %d", key, definedLine))
+		}
+
+		d.defined[name] = l.number
+		d.i++
+
+		var err error
+		if rest != "" && !isComment(rest) {
+			err = d.inline(name, rest, l, indent, len(l.text)-len(rest))
+		} else {
+			err = d.nested(name, indent, true)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// sequence reads the items of a block sequence indented by the given number of spaces.
+func (d *document) sequence(indent int, key string) error {
+	for index := 0; ; index++ {
+		d.skipBlank()
+
+		if d.done() {
+			return nil
+		}
+
+		l := d.current()
+
+		switch {
+		case l.indent < indent, !isItem(l.text):
+			return nil
+		case l.indent > indent:
+			return d.errorf(l, l.indent+1, "unexpected indentation")
+		}
+
+		name := key + "[" + strconv.Itoa(index) + "]"
+		rest := strings.TrimLeft(l.text[1:], " ")
+		offset := len(l.text) - len(rest)
+
+		if rest == "" || isComment(rest) {
+			d.i++
+
+			if err := d.nested(name, indent, false); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		// Item is a compact nested collection (`- key: value`, `- - value`), read as if it were on its own line.
+		if isEntry(rest) || isItem(rest) {
+			d.lines[d.i].indent = indent + offset
+			d.lines[d.i].text = rest
+
+			if err := d.block(indent+offset, name); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		d.i++
+
+		if err := d.inline(name, rest, l, indent, offset); err != nil {
+			return err
+		}
+	}
+}
+
+// nested reads a value starting on the line after its key or sequence indicator: a block mapping or sequence,
+// or a scalar, indented more than the parent. The sequence of a mapping key can be indented as much as the key.
+// Nothing is read for a null value.
+func (d *document) nested(name string, parentIndent int, sameIndentSequence bool) error {
+	d.skipBlank()
+
+	if d.done() {
+		return nil
+	}
+
+	next := d.current()
+
+	switch {
+	case isItem(next.text) && (next.indent > parentIndent || sameIndentSequence && next.indent == parentIndent):
+		return d.sequence(next.indent, name)
+	case next.indent <= parentIndent:
+		return nil
+	case isEntry(next.text):
+		return d.mapping(next.indent, name+".")
+	default:
+		d.i++
+		return d.inline(name, next.text, next, parentIndent, 0)
+	}
+}
+
+// block reads a nested block mapping or sequence, starting at the current line.
+func (d *document) block(indent int, name string) error {
+	if isItem(d.current().text) {
+		return d.sequence(indent, name)
+	}
+
+	return d.mapping(indent, name+".")
+}
+
+// inline reads a value written after its key or sequence indicator (`key: value`, `- value`),
+// starting at the given offset of the line. Following lines indented more than the parent
+// continue the value.
+func (d *document) inline(name, text string, l line, parentIndent, offset int) error {
+	column := l.indent + offset + 1
+
+	switch text[0] {
+	case '|', '>':
+		value, err := d.blockScalar(text, l, parentIndent, column)
+		if err != nil {
+			return err
+		}
+
+		d.set(name, value, l, column)
+
+		return nil
+	case '[', '{':
+		return d.flow(name, text, l, column)
+	case '&', '*', '!':
+		return d.errorf(l, column, "anchors, aliases and tags are not supported")
+	case '"', '\'':
+		value, end, err := quoted(text)
+		if err != nil {
+			return d.errorf(l, column+end, err.Error())
+		}
+
+		if rest := strings.TrimSpace(text[end:]); rest != "" && !isComment(rest) {
+			return d.errorf(l, column+end, "unexpected text after quoted value")
+		}
+
+		d.set(name, value, l, column)
+
+		return nil
+	}
+
+	value := plain(text)
+	if continuation := d.continuation(parentIndent); continuation != "" {
+		value += continuation
+	} else if isNull(value) {
+		return nil
+	}
+
+	d.set(name, value, l, column)
+
+	return nil
+}
+
+// continuation reads the lines continuing a plain scalar, indented more than its parent, folded into one line.
+// Empty lines are kept as line breaks.
+func (d *document) continuation(parentIndent int) string {
+	var b strings.Builder
+
+	breaks := 0
+
+	for i := d.i; i < len(d.lines); i++ {
+		l := d.lines[i]
+
+		switch {
+		case l.text == "":
+			breaks++
+			continue
+		case l.indent <= parentIndent, isComment(l.text):
+			return b.String()
+		}
+
+		if isEntry(l.text) || isItem(l.text) {
+			return b.String()
+		}
+
+		if breaks > 0 {
+			b.WriteString(strings.Repeat("\n", breaks))
+		} else {
+			b.WriteByte(' ')
+		}
+
+		b.WriteString(plain(l.text))
+
+		breaks = 0
+		d.i = i + 1
+	}
+
+	return b.String()
+}
+
+// blockScalar reads a literal (`|`) or folded (`>`) block scalar, whose header is the given text,
+// with its chomping (`-`, `+`) and indentation (`2`) indicators.
+func (d *document) blockScalar(header string, l line, parentIndent, column int) (string, error) {
+	style := header[0]
+	chomping := byte(0)
+	indent := 0
+
+	indicators := header[1:]
+	if i := strings.Index(indicators, " #"); i != -1 {
+		indicators = indicators[:i]
+	}
+
+	for _, c := range []byte(strings.TrimSpace(indicators)) {
+		switch {
+		case (c == '-' || c == '+') && chomping == 0:
+			chomping = c
+		case c >= '1' && c <= '9' && indent == 0:
+			indent = parentIndent + int(c-'0')
+		default:
+			return "", d.errorf(l, column, "invalid block scalar header")
+		}
+	}
+
+	var lines []string
+
+	for ; !d.done(); d.i++ {
+		next := d.current()
+		if strings.TrimSpace(next.raw) == "" {
+			lines = append(lines, "")
+			continue
+		}
+
+		if indent == 0 {
+			indent = next.indent
+		}
+
+		if next.indent < indent || next.indent <= parentIndent {
+			break
+		}
+
+		lines = append(lines, next.raw[indent:])
+	}
+
+	content := lines
+	for len(content) > 0 && content[len(content)-1] == "" {
+		content = content[:len(content)-1]
+	}
+
+	trailing := len(lines) - len(content)
+
+	var value string
+	if style == '|' {
+		value = strings.Join(content, "\n")
+	} else {
+		value = fold(content)
+	}
+
+	switch {
+	case chomping == '-' || len(content) == 0 && chomping != '+':
+		return value, nil
+	case chomping == '+':
+		return value + strings.Repeat("\n", min(len(content), 1)+trailing), nil
+	default:
+		return value + "\n", nil
+	}
+}
+
+// fold joins the lines of a folded block scalar: line breaks between lines become spaces, empty lines become
+// line breaks, and lines indented more than the others keep their line breaks.
+func fold(lines []string) string {
+	var b strings.Builder
+
+	for i, l := range lines {
+		if i > 0 {
+			previous := lines[i-1]
+
+			switch {
+			case l == "":
+				b.WriteByte('\n')
+			case previous == "":
+				// The line break is given by the empty lines.
+			case isMoreIndented(previous) || isMoreIndented(l):
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(' ')
+			}
+		}
+
+		b.WriteString(l)
+	}
+
+	return b.String()
+}
+
+func isMoreIndented(text string) bool {
+	return strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t")
+}
+
+// flow reads a flow collection (`[1, 2]`, `{a: 1}`), joining the following lines until it is complete.
+func (d *document) flow(name, text string, l line, column int) error {
+	text = stripComment(text)
+
+	for {
+		f := &flow{text: text}
+
+		err := f.value(name)
+		if errors.Is(err, errIncomplete) && !d.done() {
+			if next := d.current(); !next.isBlank() {
+				text += " " + stripComment(next.text)
+			}
+
+			d.i++
+
+			continue
+		}
+
+		if err != nil {
+			return d.errorf(l, column, err.Error())
+		}
+
+		if rest := strings.TrimSpace(f.text[f.i:]); rest != "" && !isComment(rest) {
+			return d.errorf(l, column, "unexpected text after flow collection")
+		}
+
+		for _, e := range f.entries {
+			d.set(e.key, e.value, l, column)
+		}
+
+		return nil
+	}
+}
+
+func (d *document) set(key, value string, l line, column int) {
+	d.vars[key] = value
+
+	if d.positions != nil {
+		d.positions[key] = parser.Position{File: d.file, Line: l.number, Column: column}
+	}
+}
+
+func (d *document) errorf(l line, column int, msg string) error {
+	return &parser.ParseError{File: d.file, Line: l.number, Column: column, Msg: msg}
+}
+
+// flow reads a flow collection.
+type flow struct {
+	text    string
+	i       int
+	entries []entry
+}
+
+func (f *flow) value(name string) error {
+	f.skipSpaces()
+
+	if f.i >= len(f.text) {
+		return errIncomplete
+	}
+
+	switch f.text[f.i] {
+	case '[':
+		return f.sequence(name)
+	case '{':
+		return f.mapping(name)
+	case '&', '*', '!':
+		return errors.New("anchors, aliases and tags are not supported")
+	}
+
+	value, err := f.scalar()
+	if err != nil {
+		return err
+	}
+
+	if !isNull(value) {
+		f.entries = append(f.entries, entry{key: name, value: value})
+	}
+
+	return nil
+}
+
+func (f *flow) sequence(name string) error {
+	f.i++
+
+	for index := 0; ; index++ {
+		f.skipSpaces()
+
+		if f.i < len(f.text) && f.text[f.i] == ']' {
+			f.i++
+			return nil
+		}
+
+		if err := f.value(name + "[" + strconv.Itoa(index) + "]"); err != nil {
+			return err
+		}
+
+		if closed, err := f.separator(']'); closed || err != nil {
+			return err
+		}
+	}
+}
+
+func (f *flow) mapping(name string) error {
+	f.i++
+
+	for {
+		f.skipSpaces()
+
+		if f.i >= len(f.text) {
+			return errIncomplete
+		}
+
+		if f.text[f.i] == '}' {
+			f.i++
+			return nil
+		}
+
+		key, err := f.scalar()
+		if err != nil {
+			return err
+		}
+
+		f.skipSpaces()
+
+		if f.i >= len(f.text) {
+			return errIncomplete
+		}
+
+		if f.text[f.i] != ':' {
+			return fmt.Errorf("expected ':' after key %q", key)
+		}
+
+		f.i++
+
+		if err := f.value(name + "." + key); err != nil {
+			return err
+		}
+
+		if closed, err := f.separator('}'); closed || err != nil {
+			return err
+		}
+	}
+}
+
+// separator reads the comma between elements, or the closing bracket or brace, reporting if the collection is closed.
+func (f *flow) separator(closing byte) (bool, error) {
+	f.skipSpaces()
+
+	if f.i >= len(f.text) {
+		return false, errIncomplete
+	}
+
+	c := f.text[f.i]
+	if c != ',' && c != closing {
+		return false, fmt.Errorf("expected ',' or '%c'", closing)
+	}
+
+	f.i++
+
+	return c == closing, nil
+}
+
+// scalar reads a quoted scalar, or a plain scalar ending before a flow indicator (`,`, `[`, `]`, `{`, `}`)
+// or a colon followed by a space.
+func (f *flow) scalar() (string, error) {
+	if c := f.text[f.i]; c == '"' || c == '\'' {
+		value, end, err := quoted(f.text[f.i:])
+		if err != nil {
+			return "", err
+		}
+
+		f.i += end
+
+		return value, nil
+	}
+
+	start := f.i
+	for f.i < len(f.text) && !strings.ContainsRune(",[]{}", rune(f.text[f.i])) && !f.atColon() {
+		f.i++
+	}
+
+	return strings.TrimSpace(f.text[start:f.i]), nil
+}
+
+// atColon reports whether the current character is a colon ending a key (followed by a space or a flow indicator).
+func (f *flow) atColon() bool {
+	if f.text[f.i] != ':' {
+		return false
+	}
+
+	return f.i+1 == len(f.text) || strings.ContainsRune(" \t,[]{}", rune(f.text[f.i+1]))
+}
+
+func (f *flow) skipSpaces() {
+	for f.i < len(f.text) && (f.text[f.i] == ' ' || f.text[f.i] == '\t') {
+		f.i++
+	}
+}
+
+// splitEntry separates the key and the rest of a mapping entry (`key: value`),
+// reporting false if the text is not an entry.
+func splitEntry(text string) (key, rest string, ok bool) {
+	if text == "" || strings.ContainsRune("[{#-", rune(text[0])) && !isPlainDash(text) {
+		return "", "", false
+	}
+
+	end := 0
+
+	if text[0] == '"' || text[0] == '\'' {
+		value, n, err := quoted(text)
+		if err != nil {
+			return "", "", false
+		}
+
+		key, end = value, n
+		for end < len(text) && text[end] == ' ' {
+			end++
+		}
+
+		if end >= len(text) || text[end] != ':' {
+			return "", "", false
+		}
+	} else {
+		end = indexOfIndicator(text)
+		if end <= 0 {
+			return "", "", false
+		}
+
+		key = strings.TrimSpace(text[:end])
+	}
+
+	if end+1 < len(text) && text[end+1] != ' ' && text[end+1] != '\t' {
+		return "", "", false
+	}
+
+	return key, strings.TrimSpace(text[end+1:]), true
+}
+
+func isEntry(text string) bool {
+	_, _, ok := splitEntry(text)
+	return ok
+}
+
+// indexOfIndicator returns the index of the colon ending a plain key (followed by a space or the end of the line),
+// or -1 if there is none before a comment.
+func indexOfIndicator(text string) int {
+	for i := range len(text) {
+		switch {
+		case text[i] == '#' && i > 0 && (text[i-1] == ' ' || text[i-1] == '\t'):
+			return -1
+		case text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ' || text[i+1] == '\t'):
+			return i
+		}
+	}
+
+	return -1
+}
+
+// isPlainDash reports whether text starting with a dash is a plain scalar (`-1`, `-key: value`),
+// not a sequence item.
+func isPlainDash(text string) bool {
+	return text[0] == '-' && !isItem(text) && !isMarker(text, documentStart)
+}
+
+// isItem reports whether the text is an item of a block sequence (`- value`).
+func isItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ") || strings.HasPrefix(text, "-\t")
+}
+
+func isComment(text string) bool {
+	return strings.HasPrefix(text, "#")
+}
+
+// isMarker reports whether the text is a document marker (`---`, `...`), optionally followed by a comment.
+func isMarker(text, marker string) bool {
+	rest, ok := strings.CutPrefix(text, marker)
+
+	return ok && (rest == "" || isComment(strings.TrimSpace(rest)) && strings.HasPrefix(rest, " "))
+}
+
+func isNull(value string) bool {
+	switch value {
+	case "", "~", "null", "Null", "NULL":
+		return true
+	default:
+		return false
+	}
+}
+
+// stripComment removes the comment from the text, considering quoted scalars.
+func stripComment(text string) string {
+	var quote byte
+
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case quote == '"' && c == '\\':
+			// Skip the escaped character.
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			// Inside quotes.
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t'):
+			return strings.TrimSpace(text[:i])
+		}
+	}
+
+	return strings.TrimSpace(text)
+}
+
+// plain returns the value of a plain scalar, without its comment.
+func plain(text string) string {
+	for i := 1; i < len(text); i++ {
+		if text[i] == '#' && (text[i-1] == ' ' || text[i-1] == '\t') {
+			return strings.TrimSpace(text[:i])
+		}
+	}
+
+	return strings.TrimSpace(text)
+}
+
+// quoted reads a single-quoted or double-quoted scalar at the start of the text,
+// returning its value and the index after the closing quote.
+func quoted(text string) (string, int, error) {
+	quote := text[0]
+
+	var b strings.Builder
+
+	for i := 1; i < len(text); i++ {
+		c := text[i]
+
+		switch {
+		case c == quote && quote == '\'' && i+1 < len(text) && text[i+1] == '\'':
+			// Escaped single quote (`''`).
+			b.WriteByte('\'')
+			i++
+		case c == quote:
+			return b.String(), i + 1, nil
+		case c == '\\' && quote == '"':
+			n, err := unescape(&b, text[i+1:])
+			if err != nil {
+				return "", i, err
+			}
+
+			i += n
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return "", len(text), errors.New("unterminated quoted value")
+}
+
+// Single character escape sequences of double-quoted scalars.
+var escapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v", 'f': "\f", 'r': "\r",
+	'e': "\x1b", ' ': " ", '"': "\"", '/': "/", '\\': "\\", 'N': "\u0085", '_': "\u00a0", 'L': "\u2028",
+	'P': "\u2029",
+}
+
+// Number of hexadecimal digits of the `\xXX`, `\uXXXX` and `\UXXXXXXXX` escape sequences.
+var hexEscapes = map[byte]int{'x': 2, 'u': 4, 'U': 8}
+
+// unescape writes the character of the escape sequence at the start of the text (after the backslash),
+// returning the length of the sequence.
+func unescape(b *strings.Builder, text string) (int, error) {
+	if text == "" {
+		return 0, errors.New("unterminated quoted value")
+	}
+
+	if s, ok := escapes[text[0]]; ok {
+		b.WriteString(s)
+		return 1, nil
+	}
+
+	length, ok := hexEscapes[text[0]]
+	if !ok {
+		return 0, fmt.Errorf("invalid escape sequence \\%c", text[0])
+	}
+
+	if len(text) <= length {
+		return 0, fmt.Errorf("invalid escape sequence \\%s", text)
+	}
+
+	code, err := strconv.ParseUint(text[1:1+length], 16, 32)
+	if err != nil || !utf8.ValidRune(rune(code)) {
+		return 0, fmt.Errorf("invalid escape sequence \\%s", text[:1+length])
+	}
+
+	b.WriteRune(rune(code))
+
+	return 1 + length, nil
+}
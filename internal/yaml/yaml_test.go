@@ -0,0 +1,287 @@
+package yaml_test
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/andreiavrammsd/config/internal/parser"
+	"github.com/andreiavrammsd/config/internal/yaml"
+)
+
+func parse(t *testing.T, input string) map[string]string {
+	t.Helper()
+
+	vars := make(map[string]string)
+	if err := yaml.Parse(strings.NewReader(input), vars, nil); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	return vars
+}
+
+func assertVars(t *testing.T, actual, expected map[string]string) {
+	t.Helper()
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("\nhave: %q\nwant: %q", actual, expected)
+	}
+}
+
+func TestParseMappings(t *testing.T) {
+	vars := parse(t, `%YAML 1.2
+--- # document
+# comment
+name: api # comment
+url: http://host:80/#fragment
+empty:
+null: ~
+redis:
+  host: localhost
+
+  port: 6379
+  tls:
+    enabled: true
+    "quoted key": 'it''s'
+timeout: 1m30s
+"dotted.key": value
+-1: negative
+...
+`)
+
+	assertVars(t, vars, map[string]string{
+		"name":                 "api",
+		"url":                  "http://host:80/#fragment",
+		"redis.host":           "localhost",
+		"redis.port":           "6379",
+		"redis.tls.enabled":    "true",
+		"redis.tls.quoted key": "it's",
+		"timeout":              "1m30s",
+		"dotted.key":           "value",
+		"-1":                   "negative",
+	})
+}
+
+func TestParseSequences(t *testing.T) {
+	vars := parse(t, `hosts:
+  - a
+  - "b"
+  -
+  - d
+ports:
+- 80
+- 443 # https
+servers:
+  - host: one
+    port: 8080
+  - host: two
+    tags:
+      - x
+  -
+    host: three
+matrix:
+  - - 1
+    - 2
+  - [3, 4]
+`)
+
+	assertVars(t, vars, map[string]string{
+		"hosts[0]":           "a",
+		"hosts[1]":           "b",
+		"hosts[3]":           "d",
+		"ports[0]":           "80",
+		"ports[1]":           "443",
+		"servers[0].host":    "one",
+		"servers[0].port":    "8080",
+		"servers[1].host":    "two",
+		"servers[1].tags[0]": "x",
+		"servers[2].host":    "three",
+		"matrix[0][0]":       "1",
+		"matrix[0][1]":       "2",
+		"matrix[1][0]":       "3",
+		"matrix[1][1]":       "4",
+	})
+}
+
+func TestParseFlowCollections(t *testing.T) {
+	vars := parse(t, `ports: [80, 443, ]
+labels: {team: core, "env": 'prod', empty: null, "a\" #": "#"} # comment
+nested: {servers: [{host: a}, {host: "b, c"}], url: http://host}
+multiline: [
+  1, # one
+  2
+]
+empty: []
+`)
+
+	assertVars(t, vars, map[string]string{
+		"ports[0]":               "80",
+		"ports[1]":               "443",
+		"labels.team":            "core",
+		"labels.env":             "prod",
+		"labels.a\" #":           "#",
+		"nested.servers[0].host": "a",
+		"nested.servers[1].host": "b, c",
+		"nested.url":             "http://host",
+		"multiline[0]":           "1",
+		"multiline[1]":           "2",
+	})
+}
+
+func TestParseScalars(t *testing.T) {
+	vars := parse(t, `double: "tab\there \"quoted\" \u00fc \x41 # not a comment"
+single: 'no \n escape'
+plain: value with spaces
+multiline: first
+  second
+
+  third
+next_line:
+  value
+literal: |
+  line 1
+    indented
+
+  line 2
+
+folded: >-
+  folded
+  text
+
+  paragraph
+keep: |+
+  kept
+
+strip: |-
+  stripped
+indented: |2
+    two spaces
+emoji: 🚀 ü
+`)
+
+	assertVars(t, vars, map[string]string{
+		"double":    "tab\there \"quoted\" ü A # not a comment",
+		"single":    `no \n escape`,
+		"plain":     "value with spaces",
+		"multiline": "first second\nthird",
+		"next_line": "value",
+		"literal":   "line 1\n  indented\n\nline 2\n",
+		"folded":    "folded text\nparagraph",
+		"keep":      "kept\n\n",
+		"strip":     "stripped",
+		"indented":  "  two spaces\n",
+		"emoji":     "🚀 ü",
+	})
+}
+
+func TestParseWithPositions(t *testing.T) {
+	file, err := os.CreateTemp(t.TempDir(), "*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := file.WriteString("a: 1\nb:\n  c: 2\nd:\n  - 3\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := file.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	defer file.Close()
+
+	vars := make(map[string]string)
+	positions := make(map[string]parser.Position)
+
+	if err := yaml.Parse(file, vars, positions); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	expected := map[string]parser.Position{
+		"a":    {File: file.Name(), Line: 1, Column: 4},
+		"b.c":  {File: file.Name(), Line: 3, Column: 6},
+		"d[0]": {File: file.Name(), Line: 5, Column: 5},
+	}
+
+	if !reflect.DeepEqual(positions, expected) {
+		t.Fatalf("\nhave: %v\nwant: %v", positions, expected)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]struct {
+		input    string
+		expected string
+	}{
+		"root sequence":      {"- a", "line 1, column 1: mapping expected"},
+		"root scalar":        {"\n\nvalue", "line 3, column 1: mapping expected"},
+		"missing colon":      {"a: 1\nb", `line 2, column 1: expected "key: value"`},
+		"indentation":        {"a: 1\n  b: 2", "line 2, column 3: unexpected indentation"},
+		"nested indentation": {"a:\n  b: 1\n    c: 2", "line 3, column 5: unexpected indentation"},
+		"sequence item":      {"a:\n  b: 1\n  - c", "line 3, column 3: unexpected sequence item"},
+		"tab":                {"a:\n\tb: 1", "line 2, column 1: tabs are not allowed in indentation"},
+		"duplicate":          {"a: 1\nb: 2\na: 3", `line 3, column 1: key "a" already defined at line 1`},
+		"unterminated":       {`a: "value`, "line 1, column 10: unterminated quoted value"},
+		"after quoted":       {`a: "value" text`, "line 1, column 11: unexpected text after quoted value"},
+		"escape":             {`a: "\q"`, `line 1, column 5: invalid escape sequence \q`},
+		"anchor":             {"a: &anchor 1", "line 1, column 4: anchors, aliases and tags are not supported"},
+		"alias":              {"a:\n  - *alias", "line 2, column 5: anchors, aliases and tags are not supported"},
+		"flow":               {"a: [1, 2", "line 1, column 4: unterminated flow collection"},
+		"flow separator":     {"a: {b: 1 c: 2}", "line 1, column 4: expected ',' or '}'"},
+		"flow key":           {"a: {b}", `line 1, column 4: expected ':' after key "b"`},
+		"after flow":         {"a: [1] 2", "line 1, column 4: unexpected text after flow collection"},
+		"block header":       {"a: |x\n  b", "line 1, column 4: invalid block scalar header"},
+		"documents":          {"a: 1\n---\nb: 2", "line 2, column 1: multiple documents are not supported"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := yaml.Parse(strings.NewReader(test.input), make(map[string]string), nil)
+
+			var parseErr *parser.ParseError
+			if !errors.As(err, &parseErr) {
+				t.Fatal("incorrect error:", err)
+			}
+
+			if err.Error() != test.expected {
+				t.Fatalf("incorrect error message: %q", err)
+			}
+		})
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	assertVars(t, parse(t, ""), map[string]string{})
+	assertVars(t, parse(t, "# comment\n---\n"), map[string]string{})
+}
+
+func FuzzParse(f *testing.F) {
+	testcases := []string{
+		"a: 1\nb:\n  - c\n  - d: [1, {e: f}]\n",
+		"a: |\n  text\nb: >-\n  folded\n",
+		"- a",
+		`a: "ü"`,
+		"",
+	}
+	for _, tc := range testcases {
+		f.Add(tc)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		varsFirst := make(map[string]string)
+		errFirst := yaml.Parse(strings.NewReader(input), varsFirst, nil)
+
+		varsSecond := make(map[string]string)
+		errSecond := yaml.Parse(strings.NewReader(input), varsSecond, nil)
+
+		if (errFirst == nil) != (errSecond == nil) {
+			t.Errorf("Before: %v, after: %v", errFirst, errSecond)
+		}
+
+		if !reflect.DeepEqual(varsFirst, varsSecond) {
+			t.Error("Different results")
+		}
+	})
+}
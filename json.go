@@ -0,0 +1,218 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andreiavrammsd/config/internal/parser"
+)
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// parseJSON flattens a JSON object into variables keyed by the path of each value (`redis.port`, `ports[0]`,
+// `servers[0].host`), holding the text of strings, numbers and booleans, located by the position of their key.
+// The documents of all values, objects and arrays included, are added to documents by their lowercase key,
+// to be decoded as a whole by the fields implementing json.Unmarshaler. Null values are skipped.
+func parseJSON(
+	input []byte, vars map[string]string, positions map[string]parser.Position, documents map[string]jsonDocument,
+) error {
+	if err := json.Unmarshal(input, new(json.RawMessage)); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) && len(input) > 0 {
+			line, column := position(input, syntaxErr.Offset)
+			return &ParseError{Line: line, Column: column, Msg: syntaxErr.Error(), Err: err}
+		}
+
+		return fmt.Errorf("%w", err)
+	}
+
+	f := &jsonFlattener{input: input, lines: lineStarts(input), vars: vars, positions: positions, documents: documents}
+	f.decoder = json.NewDecoder(bytes.NewReader(input))
+	f.decoder.UseNumber()
+
+	start := f.offset()
+
+	token, err := f.decoder.Token()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	if token != json.Delim('{') {
+		line, column := position(input, start+1)
+		return &ParseError{Line: line, Column: column, Msg: "object expected"}
+	}
+
+	return f.object("")
+}
+
+// jsonFlattener reads the tokens of a JSON input into variables.
+type jsonFlattener struct {
+	decoder   *json.Decoder
+	input     []byte
+	lines     []int64
+	vars      map[string]string
+	positions map[string]parser.Position
+	documents map[string]jsonDocument
+}
+
+// jsonDocument is the text of a value with its key.
+type jsonDocument struct {
+	key  string
+	text string
+}
+
+// object reads the members of an object, after its opening brace.
+func (f *jsonFlattener) object(prefix string) error {
+	for f.decoder.More() {
+		start := f.offset()
+
+		token, err := f.decoder.Token()
+		if err != nil {
+			return fmt.Errorf("%w", err)
+		}
+
+		key, _ := token.(string)
+		if err := f.value(prefix+key, start); err != nil {
+			return err
+		}
+	}
+
+	return f.end()
+}
+
+// array reads the elements of an array, after its opening bracket.
+func (f *jsonFlattener) array(key string) error {
+	for i := 0; f.decoder.More(); i++ {
+		if err := f.value(key+"["+strconv.Itoa(i)+"]", f.offset()); err != nil {
+			return err
+		}
+	}
+
+	return f.end()
+}
+
+// value reads the value of a key, located at the given offset.
+func (f *jsonFlattener) value(key string, start int64) error {
+	valueStart := f.offset()
+
+	token, err := f.decoder.Token()
+	if err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	switch token := token.(type) {
+	case json.Delim:
+		line, column := f.position(start + 1)
+		f.positions[key] = parser.Position{Line: line, Column: column}
+
+		if token == '{' {
+			err = f.object(key + ".")
+		} else {
+			err = f.array(key)
+		}
+
+		if err != nil {
+			return err
+		}
+	case string:
+		f.set(key, token, start)
+	case json.Number:
+		f.set(key, token.String(), start)
+	case bool:
+		f.set(key, strconv.FormatBool(token), start)
+	default:
+		// Null values are not set.
+		return nil
+	}
+
+	// The document ends with the value, once it is read.
+	f.documents[strings.ToLower(key)] = jsonDocument{key: key, text: string(f.input[valueStart:f.decoder.InputOffset()])}
+
+	return nil
+}
+
+// end reads the closing brace or bracket of an object or array.
+func (f *jsonFlattener) end() error {
+	if _, err := f.decoder.Token(); err != nil {
+		return fmt.Errorf("%w", err)
+	}
+
+	return nil
+}
+
+func (f *jsonFlattener) set(key, value string, start int64) {
+	line, column := f.position(start + 1)
+
+	f.vars[key] = value
+	f.positions[key] = parser.Position{Line: line, Column: column}
+}
+
+// position converts an offset in the input to a line and column, as the position function does,
+// searching the line among the offsets where the lines start.
+func (f *jsonFlattener) position(offset int64) (line, column int) {
+	offset = min(max(offset, 1), int64(len(f.input)))
+	line = sort.Search(len(f.lines), func(i int) bool { return f.lines[i] > offset-1 })
+
+	return line, int(offset - f.lines[line-1])
+}
+
+// lineStarts returns the offsets where the lines of the input start.
+func lineStarts(input []byte) []int64 {
+	starts := []int64{0}
+
+	for i, c := range input {
+		if c == '\n' {
+			starts = append(starts, int64(i+1))
+		}
+	}
+
+	return starts
+}
+
+// offset returns the offset of the next token, skipping the whitespace and the separators
+// which the decoder did not read yet.
+func (f *jsonFlattener) offset() int64 {
+	offset := f.decoder.InputOffset()
+
+	for offset < int64(len(f.input)) && bytes.IndexByte([]byte(" \t\r\n,:"), f.input[offset]) != -1 {
+		offset++
+	}
+
+	return offset
+}
+
+// jsonSource holds the variables of a JSON input and the documents of its keys, decoded by json.Unmarshaler
+// (json.RawMessage too), empty interface (`any`) and byte slice (base64 strings) values, as by encoding/json.
+type jsonSource struct {
+	varsSource
+	documents map[string]jsonDocument
+}
+
+func (s jsonSource) Document(key string) (string, string) {
+	if document, ok := s.documents[strings.ToLower(key)]; ok {
+		return document.key, document.text
+	}
+
+	return key, ""
+}
+
+func (jsonSource) Decodes(typ reflect.Type) bool {
+	switch {
+	case typ.Kind() == reflect.Interface && typ.NumMethod() == 0:
+		return true
+	case typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8:
+		return true
+	}
+
+	return typ.Implements(jsonUnmarshalerType) || reflect.PointerTo(typ).Implements(jsonUnmarshalerType)
+}
+
+func (jsonSource) Decode(document string, v reflect.Value) error {
+	return json.Unmarshal([]byte(document), v.Addr().Interface()) //nolint:wrapcheck
+}
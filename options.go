@@ -0,0 +1,131 @@
+package config
+
+import (
+	"reflect"
+
+	"github.com/andreiavrammsd/config/internal/interpolator"
+	"github.com/andreiavrammsd/config/internal/parser"
+	"github.com/andreiavrammsd/config/internal/reader"
+)
+
+// Option configures the behavior of Config.
+type Option func(*options)
+
+type options struct {
+	reader        []reader.Option
+	parser        []parser.Option
+	interpolator  []interpolator.Option
+	interpolation bool
+	strict        bool
+}
+
+// WithTagName sets the tag holding the key of a field (`env` by default).
+func WithTagName(name string) Option {
+	return func(o *options) {
+		o.reader = append(o.reader, reader.WithTag(name))
+	}
+}
+
+// WithDefaultTag sets the tag holding the default value of a field (`default` by default).
+func WithDefaultTag(name string) Option {
+	return func(o *options) {
+		o.reader = append(o.reader, reader.WithDefaultTag(name))
+	}
+}
+
+// WithKeyTransform sets the function which generates the key of a field without tag from its path,
+// the names of the fields from root to it joined by underscore (`Redis_Port`). Default is strings.ToUpper.
+func WithKeyTransform(transform func(path string) string) Option {
+	return func(o *options) {
+		o.reader = append(o.reader, reader.WithKeyTransform(transform))
+	}
+}
+
+// WithPrefix adds a prefix to all keys (`APP_` reads `APP_PORT` into the `Port` field).
+func WithPrefix(prefix string) Option {
+	return func(o *options) {
+		o.reader = append(o.reader, reader.WithPrefix(prefix))
+	}
+}
+
+// WithInterpolation enables or disables variables interpolation in dotenv, TOML and INI input (enabled by default).
+func WithInterpolation(enabled bool) Option {
+	return func(o *options) {
+		o.interpolation = enabled
+	}
+}
+
+// WithoutEnvLookup makes interpolation of dotenv, TOML and INI input not look up variables in the environment,
+// which it does by default for variables not defined in the input.
+func WithoutEnvLookup() Option {
+	return func(o *options) {
+		o.interpolator = append(o.interpolator, interpolator.WithoutEnv())
+	}
+}
+
+// WithLookup adds a function to look up variables interpolated in dotenv, TOML and INI input which are not defined
+// in the input or in the environment. Lookup functions are used in the order they are added.
+func WithLookup(lookup func(name string) (value string, ok bool)) Option {
+	return func(o *options) {
+		o.interpolator = append(o.interpolator, interpolator.WithLookup(lookup))
+	}
+}
+
+// WithUndefined sets the handling of references to variables which are not set in dotenv, TOML and INI input:
+// UndefinedEmpty (default) replaces them with an empty string, UndefinedKeep keeps them literally (`$VAR`)
+// and UndefinedError fails with *ParseError (matching ErrUndefined) for each key, listing its undefined variables.
+func WithUndefined(policy UndefinedPolicy) Option {
+	return func(o *options) {
+		o.interpolator = append(o.interpolator, interpolator.WithUndefined(policy))
+	}
+}
+
+// WithStrict makes dotenv files, bytes, JSON, YAML, TOML and INI fail with ErrUnknownKeys if they contain keys
+// which are not bound to any field.
+func WithStrict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// WithLegacyComments makes comments in dotenv files and bytes begin at any `#` outside quotes
+// (`KEY=value#comment`), instead of only at line start or after whitespace (`KEY=value #comment`).
+func WithLegacyComments() Option {
+	return func(o *options) {
+		o.parser = append(o.parser, parser.WithLegacyComments())
+	}
+}
+
+// WithStrictSyntax makes malformed dotenv files and bytes fail with Errors of *ParseError, holding the position of
+// each problem: lines without `=`, invalid keys (`$KEY`, `1KEY`), unterminated quoted values,
+// text after a quoted value or after a key. By default, malformed lines are skipped.
+func WithStrictSyntax() Option {
+	return func(o *options) {
+		o.parser = append(o.parser, parser.WithStrictSyntax())
+	}
+}
+
+// WithDuplicates sets the handling of keys defined more than once in dotenv files and bytes,
+// within one file or across the files passed to FromFile: DuplicateLastWins (default), DuplicateFirstWins
+// or DuplicateError, which fails with *ParseError naming both locations.
+func WithDuplicates(policy DuplicatePolicy) Option {
+	return func(o *options) {
+		o.parser = append(o.parser, parser.WithDuplicates(policy))
+	}
+}
+
+// WithParser registers a function to convert values to the given type,
+// useful for types which cannot implement Decoder (`netip.Prefix`, `*x509.CertPool`).
+// It takes precedence over any other conversion of the type.
+func WithParser(typ reflect.Type, parse func(value string) (any, error)) Option {
+	return func(o *options) {
+		o.reader = append(o.reader, reader.WithParser(typ, parse))
+	}
+}
+
+// RegisterParser registers a function to convert values to type T. See WithParser.
+func RegisterParser[T any](parse func(value string) (T, error)) Option {
+	return WithParser(reflect.TypeOf((*T)(nil)).Elem(), func(value string) (any, error) {
+		return parse(value)
+	})
+}
@@ -64,7 +64,7 @@ func ReadInputFile(file string) []byte {
 
 func GetExpectedOutput() Config {
 	return Config{
-		String: " string\\\" ",
+		String: " string\" ",
 		A:      1,
 		B:      2,
 		C:      3,
@@ -97,6 +97,9 @@ func GetExpectedOutput() Config {
 		Struct: Struct{
 			Field: "Value",
 		},
+		StructPtr: &Struct{
+			Field: "Val\"ue ",
+		},
 		Mongo: struct {
 			Database struct {
 				Host       string `env:"MONGO_DATABASE_HOST"`